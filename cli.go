@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/logprocessor"
+)
+
+// patternsFlag collects a repeatable include or exclude filter flag.
+type patternsFlag []logprocessor.Pattern
+
+func (f *patternsFlag) String() string {
+	var s []string
+	for _, p := range *f {
+		s = append(s, p.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *patternsFlag) Set(v string) error {
+	patterns, err := logprocessor.ParsePatterns(v)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, patterns...)
+	return nil
+}
+
+// stringsFlag collects the values of a repeatable flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// runCLI parses command line arguments and processes the given input.
+func runCLI(ctx context.Context, proc *logprocessor.LogProcessor, args []string) error {
+	fs := flag.NewFlagSet("aws-lb-log-forwarder", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: aws-lb-log-forwarder [flags] <s3-url|path|glob|->...\n       aws-lb-log-forwarder serve|webhook")
+		fs.PrintDefaults()
+	}
+
+	since := fs.String("since", "", "only process log files from this time on (RFC 3339, YYYY-MM-DDTHH:MM or YYYY-MM-DD, UTC)")
+	until := fs.String("until", "", "only process log files before this time (default: now when --since is set)")
+
+	var opts logprocessor.BackfillOptions
+	fs.Var((*patternsFlag)(&opts.Accounts.Include), "account", "only process these account IDs (glob list or /regex/, repeatable)")
+	fs.Var((*patternsFlag)(&opts.Accounts.Exclude), "exclude-account", "skip these account IDs")
+	fs.Var((*patternsFlag)(&opts.Regions.Include), "region", "only process these regions")
+	fs.Var((*patternsFlag)(&opts.Regions.Exclude), "exclude-region", "skip these regions")
+	fs.Var((*patternsFlag)(&opts.LoadBalancers.Include), "lb", "only process these load balancers (e.g. app.prod-api)")
+	fs.Var((*patternsFlag)(&opts.LoadBalancers.Exclude), "exclude-lb", "skip these load balancers")
+
+	var manifests stringsFlag
+	fs.Var(&manifests, "manifest", "also process the objects listed in this file or S3 object: s3:// URLs, one per line, or an S3 Inventory CSV or manifest.json (repeatable)")
+
+	opts.Archived = &logprocessor.ArchivedObjects{}
+	fs.BoolVar(&opts.Archived.Restore, "restore", false, "request a restore of objects in Glacier and Deep Archive instead of skipping them, and process those already restored")
+	fs.Int64Var(&opts.Archived.RestoreDays, "restore-days", 7, "days restored copies stay available")
+	fs.StringVar(&opts.Archived.RestoreTier, "restore-tier", "Bulk", "restore retrieval tier: "+strings.Join(logprocessor.RestoreTiers, ", "))
+	fs.StringVar(&opts.Archived.PendingFile, "pending", "", "write archived objects that were skipped or await restore to this file")
+
+	checkpoint := fs.String("checkpoint", "", "record progress in this file")
+	resume := fs.Bool("resume", false, "skip work recorded in the --checkpoint file by an earlier run")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 && len(manifests) == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing input")
+	}
+
+	var err error
+	if *since != "" {
+		if opts.Since, err = logprocessor.ParseTime(*since); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+	if *until != "" {
+		if opts.Until, err = logprocessor.ParseTime(*until); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	if !slices.Contains(logprocessor.RestoreTiers, opts.Archived.RestoreTier) {
+		return fmt.Errorf("invalid --restore-tier: %q", opts.Archived.RestoreTier)
+	}
+
+	if *resume && *checkpoint == "" {
+		return fmt.Errorf("--resume requires --checkpoint")
+	}
+	if *checkpoint != "" {
+		if opts.Checkpoint, err = logprocessor.OpenCheckpoint(*checkpoint, *resume); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("processing input", "inputs", fs.Args(), "manifests", manifests)
+	err = proc.HandleInputs(ctx, fs.Args(), manifests, opts)
+
+	// Save the final progress, also when interrupted or failed, so --resume continues from here
+	if saveErr := opts.Checkpoint.Save(); saveErr != nil {
+		slog.Error("checkpoint save failed", "error", saveErr)
+	}
+	if reportErr := opts.Archived.Report(); reportErr != nil {
+		slog.Error("archived objects report failed", "error", reportErr)
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+#!/bin/bash
+# Test: DynamoDB ledger skips objects that were already forwarded
+set -e
+
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+BINARY="$SCRIPT_DIR/aws-lb-log-forwarder"
+LOCALSTACK_ENDPOINT="${LOCALSTACK_ENDPOINT:-http://localhost:4566}"
+
+BUCKET="e2e-ledger-test"
+TABLE="e2e-ledger"
+
+NOW=$(date -u +"%Y-%m-%dT%H:%M:%S.000000Z")
+
+# Sample ALB log entry (30 fields)
+LOG_ENTRY="https ${NOW} app/my-alb/abc 192.168.1.100:54321 10.0.1.50:8080 0.001 0.015 0.000 200 200 256 1024 \"GET https://api.example.com:443/users HTTP/1.1\" \"Mozilla/5.0\" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:us-east-1:123456789:tg/tg/abc \"Root=1-abc\" \"api.example.com\" \"arn:aws:acm:us-east-1:123456789:cert/abc\" 0 ${NOW} \"forward\" \"-\" \"-\" \"10.0.1.50:8080\" \"200\" \"-\" \"-\" \"-\""
+
+# Create gzipped log file
+TEMP_LOG=$(mktemp)
+TEMP_GZ="${TEMP_LOG}.gz"
+echo "$LOG_ENTRY" > "$TEMP_LOG"
+gzip -c "$TEMP_LOG" > "$TEMP_GZ"
+
+# Setup S3 and DynamoDB
+aws --endpoint-url="$LOCALSTACK_ENDPOINT" s3 mb "s3://$BUCKET" 2>/dev/null || true
+aws --endpoint-url="$LOCALSTACK_ENDPOINT" s3 cp "$TEMP_GZ" "s3://$BUCKET/logs/test.log.gz"
+aws --endpoint-url="$LOCALSTACK_ENDPOINT" dynamodb create-table \
+    --table-name "$TABLE" \
+    --attribute-definitions AttributeName=id,AttributeType=S \
+    --key-schema AttributeName=id,KeyType=HASH \
+    --billing-mode PAY_PER_REQUEST >/dev/null 2>&1 || true
+
+export AWS_ENDPOINT_URL="$LOCALSTACK_ENDPOINT"
+export AWS_ACCESS_KEY_ID="test"
+export AWS_SECRET_ACCESS_KEY="test"
+export AWS_REGION="eu-west-1"
+export DESTINATIONS="stdout"
+export LEDGER="dynamodb"
+export LEDGER_DYNAMODB_TABLE="$TABLE"
+
+FIRST=$("$BINARY" "s3://$BUCKET/logs/" | grep -c "elb_status_code" || true)
+SECOND=$("$BINARY" "s3://$BUCKET/logs/" | grep -c "elb_status_code" || true)
+
+if [ "$FIRST" -ne 1 ]; then
+    echo "ERROR: Expected 1 entry on first run, got $FIRST"
+    exit 1
+fi
+
+if [ "$SECOND" -ne 0 ]; then
+    echo "ERROR: Expected already forwarded object to be skipped, got $SECOND entries"
+    exit 1
+fi
+
+echo "ledger verified: first run $FIRST entries, second run $SECOND entries"
+
+# Cleanup
+rm -f "$TEMP_LOG" "$TEMP_GZ"
+aws --endpoint-url="$LOCALSTACK_ENDPOINT" dynamodb delete-table --table-name "$TABLE" >/dev/null 2>&1 || true
+aws --endpoint-url="$LOCALSTACK_ENDPOINT" s3 rb "s3://$BUCKET" --force 2>/dev/null || true
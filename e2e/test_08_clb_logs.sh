@@ -0,0 +1,49 @@
+#!/bin/bash
+# Test: Classic Load Balancer log processing (uncompressed objects)
+set -e
+
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+BINARY="$SCRIPT_DIR/aws-lb-log-forwarder"
+LOCALSTACK_ENDPOINT="${LOCALSTACK_ENDPOINT:-http://localhost:4566}"
+
+BUCKET="e2e-clb-test"
+
+NOW=$(date -u +"%Y-%m-%dT%H:%M:%S.000000Z")
+
+# Sample CLB log entry (15 fields - each field space-separated)
+LOG_ENTRY="${NOW} my-clb 192.168.1.100:54321 10.0.1.50:80 0.000073 0.001048 0.000057 200 200 0 29 \"GET http://www.example.com:80/ HTTP/1.1\" \"curl/7.38.0\" - -"
+
+# CLB writes plain text log files
+TEMP_LOG=$(mktemp)
+echo "$LOG_ENTRY" > "$TEMP_LOG"
+
+# Setup S3
+aws --endpoint-url="$LOCALSTACK_ENDPOINT" s3 mb "s3://$BUCKET" 2>/dev/null || true
+aws --endpoint-url="$LOCALSTACK_ENDPOINT" s3 cp "$TEMP_LOG" "s3://$BUCKET/logs/test.log"
+
+# Run forwarder in CLB mode
+export AWS_ENDPOINT_URL="$LOCALSTACK_ENDPOINT"
+export AWS_ACCESS_KEY_ID="test"
+export AWS_SECRET_ACCESS_KEY="test"
+export AWS_REGION="eu-west-1"
+export DESTINATIONS="stdout"
+export LB_TYPE="clb"
+
+OUTPUT=$("$BINARY" "s3://$BUCKET/logs/")
+
+# Verify CLB-specific fields are present
+if ! echo "$OUTPUT" | grep -q "backend_processing_time"; then
+    echo "ERROR: Missing backend_processing_time (CLB-specific field)"
+    exit 1
+fi
+
+if ! echo "$OUTPUT" | grep -q "my-clb"; then
+    echo "ERROR: Missing load balancer name"
+    exit 1
+fi
+
+echo "CLB log processing verified"
+
+# Cleanup
+rm -f "$TEMP_LOG"
+aws --endpoint-url="$LOCALSTACK_ENDPOINT" s3 rb "s3://$BUCKET" --force 2>/dev/null || true
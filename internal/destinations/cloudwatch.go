@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -65,9 +66,10 @@ func NewCloudWatch(sess *session.Session) (*CloudWatch, error) {
 }
 
 // SendLogs receives entries and batches them to CloudWatch.
-func (c *CloudWatch) SendLogs(ctx context.Context, entries <-chan types.LogEntry) {
+func (c *CloudWatch) SendLogs(ctx context.Context, entries <-chan types.LogEntry) error {
 	var batch []*cloudwatchlogs.InputLogEvent
 	var batchSize int
+	var sendErr error
 
 	ticker := time.NewTicker(flushInterval)
 	defer ticker.Stop()
@@ -76,7 +78,10 @@ func (c *CloudWatch) SendLogs(ctx context.Context, entries <-chan types.LogEntry
 		if len(batch) == 0 {
 			return
 		}
-		c.send(batch)
+		// Keep sending the remaining batches; the first error is returned
+		if err := c.send(batch); err != nil && sendErr == nil {
+			sendErr = err
+		}
 		batch = nil
 		batchSize = 0
 	}
@@ -85,17 +90,27 @@ func (c *CloudWatch) SendLogs(ctx context.Context, entries <-chan types.LogEntry
 		select {
 		case <-ctx.Done():
 			flush()
-			return
+			return ctx.Err()
 
 		case entry, ok := <-entries:
 			if !ok {
 				flush()
-				return
+				return sendErr
+			}
+
+			// Event timestamps are in milliseconds, so the message has the full
+			// timestamp, under a key no log field uses (as in OpenSearch)
+			msg := make(map[string]any, len(entry.Data)+1)
+			for k, v := range entry.Data {
+				msg[k] = v
 			}
+			msg["@timestamp"] = entry.Timestamp.Format(time.RFC3339Nano)
 
-			data, err := json.Marshal(entry.Data)
+			data, err := json.Marshal(msg)
 			if err != nil {
-				slog.Error("marshal failed", "error", err)
+				if sendErr == nil {
+					sendErr = fmt.Errorf("marshal: %w", err)
+				}
 				continue
 			}
 
@@ -119,19 +134,40 @@ func (c *CloudWatch) SendLogs(ctx context.Context, entries <-chan types.LogEntry
 	}
 }
 
-func (c *CloudWatch) send(events []*cloudwatchlogs.InputLogEvent) {
+func (c *CloudWatch) send(events []*cloudwatchlogs.InputLogEvent) error {
 	sort.Slice(events, func(i, j int) bool {
 		return *events[i].Timestamp < *events[j].Timestamp
 	})
 
-	_, err := c.client.PutLogEvents(&cloudwatchlogs.PutLogEventsInput{
+	resp, err := c.client.PutLogEvents(&cloudwatchlogs.PutLogEventsInput{
 		LogEvents:     events,
 		LogGroupName:  aws.String(c.logGroup),
 		LogStreamName: aws.String(c.logStream),
 	})
 	if err != nil {
-		slog.Error("put events failed", "error", err)
+		return fmt.Errorf("put log events: %w", err)
+	}
+
+	// Events outside the accepted time range are dropped without an error
+	if info := resp.RejectedLogEventsInfo; info != nil {
+		return fmt.Errorf("put log events: %s", rejectedEvents(info))
+	}
+	return nil
+}
+
+// rejectedEvents describes which events of a batch CloudWatch rejected.
+func rejectedEvents(info *cloudwatchlogs.RejectedLogEventsInfo) string {
+	var reasons []string
+	if info.TooOldLogEventEndIndex != nil {
+		reasons = append(reasons, fmt.Sprintf("too old up to index %d", *info.TooOldLogEventEndIndex))
+	}
+	if info.ExpiredLogEventEndIndex != nil {
+		reasons = append(reasons, fmt.Sprintf("expired up to index %d", *info.ExpiredLogEventEndIndex))
+	}
+	if info.TooNewLogEventStartIndex != nil {
+		reasons = append(reasons, fmt.Sprintf("too new from index %d", *info.TooNewLogEventStartIndex))
 	}
+	return "events rejected: " + strings.Join(reasons, ", ")
 }
 
 func ensureLogGroup(client CloudWatchAPI, name string) error {
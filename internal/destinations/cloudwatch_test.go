@@ -119,9 +119,43 @@ func TestSend(t *testing.T) {
 			logStream: "test-log-stream",
 		}
 
-		cw.send(events)
+		require.NoError(t, cw.send(events))
 		mockClient.AssertExpectations(t)
 	})
+
+	t.Run("Send error", func(t *testing.T) {
+		mockClient := new(MockCloudWatchClient)
+		mockClient.On("PutLogEvents", mock.Anything).Return((*cloudwatchlogs.PutLogEventsOutput)(nil), assert.AnError)
+
+		cw := &CloudWatch{
+			client:    mockClient,
+			logGroup:  "test-log-group",
+			logStream: "test-log-stream",
+		}
+
+		err := cw.send([]*cloudwatchlogs.InputLogEvent{{Message: aws.String("message1"), Timestamp: aws.Int64(1)}})
+		require.ErrorIs(t, err, assert.AnError)
+	})
+
+	t.Run("Rejected events", func(t *testing.T) {
+		mockClient := new(MockCloudWatchClient)
+		mockClient.On("PutLogEvents", mock.Anything).Return(&cloudwatchlogs.PutLogEventsOutput{
+			RejectedLogEventsInfo: &cloudwatchlogs.RejectedLogEventsInfo{TooOldLogEventEndIndex: aws.Int64(1)},
+		}, nil)
+
+		cw := &CloudWatch{
+			client:    mockClient,
+			logGroup:  "test-log-group",
+			logStream: "test-log-stream",
+		}
+
+		err := cw.send([]*cloudwatchlogs.InputLogEvent{
+			{Message: aws.String("message1"), Timestamp: aws.Int64(1)},
+			{Message: aws.String("message2"), Timestamp: aws.Int64(2)},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "too old up to index 1")
+	})
 }
 
 func TestCloudWatch_SendLogs(t *testing.T) {
@@ -138,19 +172,19 @@ func TestCloudWatch_SendLogs(t *testing.T) {
 		entries := make(chan types.LogEntry, 3)
 		entries <- types.LogEntry{
 			Timestamp: time.Date(2024, 3, 21, 10, 15, 30, 0, time.UTC),
-			Data:      map[string]string{"request": "GET /api/users", "status": "200"},
+			Data:      map[string]any{"request": "GET /api/users", "status": "200"},
 		}
 		entries <- types.LogEntry{
 			Timestamp: time.Date(2024, 3, 21, 10, 15, 31, 0, time.UTC),
-			Data:      map[string]string{"request": "POST /api/users", "status": "201"},
+			Data:      map[string]any{"request": "POST /api/users", "status": "201"},
 		}
 		entries <- types.LogEntry{
 			Timestamp: time.Date(2024, 3, 21, 10, 15, 32, 0, time.UTC),
-			Data:      map[string]string{"request": "DELETE /api/users/1", "status": "204"},
+			Data:      map[string]any{"request": "DELETE /api/users/1", "status": "204"},
 		}
 		close(entries)
 
-		cw.SendLogs(context.Background(), entries)
+		require.NoError(t, cw.SendLogs(context.Background(), entries))
 
 		mockClient.AssertCalled(t, "PutLogEvents", mock.MatchedBy(func(input *cloudwatchlogs.PutLogEventsInput) bool {
 			return *input.LogGroupName == "test-group" &&
@@ -173,7 +207,7 @@ func TestCloudWatch_SendLogs(t *testing.T) {
 		entries := make(chan types.LogEntry, 1)
 		entries <- types.LogEntry{
 			Timestamp: time.Now(),
-			Data:      map[string]string{"message": "test"},
+			Data:      map[string]any{"message": "test"},
 		}
 
 		done := make(chan struct{})
@@ -190,6 +224,23 @@ func TestCloudWatch_SendLogs(t *testing.T) {
 		mockClient.AssertCalled(t, "PutLogEvents", mock.Anything)
 	})
 
+	t.Run("Failed batch is returned", func(t *testing.T) {
+		mockClient := new(MockCloudWatchClient)
+		mockClient.On("PutLogEvents", mock.Anything).Return((*cloudwatchlogs.PutLogEventsOutput)(nil), assert.AnError)
+
+		cw := &CloudWatch{
+			client:    mockClient,
+			logGroup:  "test-group",
+			logStream: "test-stream",
+		}
+
+		entries := make(chan types.LogEntry, 1)
+		entries <- types.LogEntry{Timestamp: time.Now(), Data: map[string]any{"message": "test"}}
+		close(entries)
+
+		require.ErrorIs(t, cw.SendLogs(context.Background(), entries), assert.AnError)
+	})
+
 	t.Run("Events are sorted by timestamp", func(t *testing.T) {
 		mockClient := new(MockCloudWatchClient)
 		var capturedInput *cloudwatchlogs.PutLogEventsInput
@@ -207,19 +258,19 @@ func TestCloudWatch_SendLogs(t *testing.T) {
 		// Send out of order
 		entries <- types.LogEntry{
 			Timestamp: time.Date(2024, 3, 21, 10, 15, 32, 0, time.UTC),
-			Data:      map[string]string{"order": "third"},
+			Data:      map[string]any{"order": "third"},
 		}
 		entries <- types.LogEntry{
 			Timestamp: time.Date(2024, 3, 21, 10, 15, 30, 0, time.UTC),
-			Data:      map[string]string{"order": "first"},
+			Data:      map[string]any{"order": "first"},
 		}
 		entries <- types.LogEntry{
 			Timestamp: time.Date(2024, 3, 21, 10, 15, 31, 0, time.UTC),
-			Data:      map[string]string{"order": "second"},
+			Data:      map[string]any{"order": "second"},
 		}
 		close(entries)
 
-		cw.SendLogs(context.Background(), entries)
+		require.NoError(t, cw.SendLogs(context.Background(), entries))
 
 		require.NotNil(t, capturedInput)
 		require.Len(t, capturedInput.LogEvents, 3)
@@ -229,4 +280,59 @@ func TestCloudWatch_SendLogs(t *testing.T) {
 			assert.LessOrEqual(t, *capturedInput.LogEvents[i-1].Timestamp, *capturedInput.LogEvents[i].Timestamp)
 		}
 	})
+
+	t.Run("Message has the full timestamp", func(t *testing.T) {
+		mockClient := new(MockCloudWatchClient)
+		var capturedInput *cloudwatchlogs.PutLogEventsInput
+		mockClient.On("PutLogEvents", mock.Anything).Run(func(args mock.Arguments) {
+			capturedInput = args.Get(0).(*cloudwatchlogs.PutLogEventsInput)
+		}).Return(&cloudwatchlogs.PutLogEventsOutput{}, nil)
+
+		cw := &CloudWatch{
+			client:    mockClient,
+			logGroup:  "test-group",
+			logStream: "test-stream",
+		}
+
+		entries := make(chan types.LogEntry, 1)
+		entries <- types.LogEntry{
+			Timestamp: time.Date(2024, 3, 21, 10, 15, 30, 123456789, time.UTC),
+			Data:      map[string]any{"status": 200},
+		}
+		close(entries)
+
+		require.NoError(t, cw.SendLogs(context.Background(), entries))
+
+		require.NotNil(t, capturedInput)
+		require.Len(t, capturedInput.LogEvents, 1)
+		assert.Equal(t, int64(1711016130123), *capturedInput.LogEvents[0].Timestamp)
+		assert.JSONEq(t, `{"status":200,"@timestamp":"2024-03-21T10:15:30.123456789Z"}`, *capturedInput.LogEvents[0].Message)
+	})
+
+	t.Run("Timestamp field of the entry is kept", func(t *testing.T) {
+		mockClient := new(MockCloudWatchClient)
+		var capturedInput *cloudwatchlogs.PutLogEventsInput
+		mockClient.On("PutLogEvents", mock.Anything).Run(func(args mock.Arguments) {
+			capturedInput = args.Get(0).(*cloudwatchlogs.PutLogEventsInput)
+		}).Return(&cloudwatchlogs.PutLogEventsOutput{}, nil)
+
+		cw := &CloudWatch{
+			client:    mockClient,
+			logGroup:  "test-group",
+			logStream: "test-stream",
+		}
+
+		entries := make(chan types.LogEntry, 1)
+		entries <- types.LogEntry{
+			Timestamp: time.Date(2024, 3, 21, 10, 15, 30, 0, time.UTC),
+			Data:      map[string]any{"timestamp": "1711016130"},
+		}
+		close(entries)
+
+		require.NoError(t, cw.SendLogs(context.Background(), entries))
+
+		require.NotNil(t, capturedInput)
+		require.Len(t, capturedInput.LogEvents, 1)
+		assert.JSONEq(t, `{"timestamp":"1711016130","@timestamp":"2024-03-21T10:15:30Z"}`, *capturedInput.LogEvents[0].Message)
+	})
 }
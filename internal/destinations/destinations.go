@@ -12,7 +12,9 @@ import (
 
 // Destination receives log entries and sends them to a destination.
 type Destination interface {
-	SendLogs(ctx context.Context, entries <-chan types.LogEntry)
+	// SendLogs sends entries until the channel is closed or ctx is done. It
+	// returns an error if any entries could not be delivered.
+	SendLogs(ctx context.Context, entries <-chan types.LogEntry) error
 }
 
 // New creates destinations from a comma-separated configuration string.
@@ -6,7 +6,6 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"log/slog"
 	"net/http"
 	"os"
 	"time"
@@ -60,9 +59,10 @@ func NewOpenSearch() (*OpenSearch, error) {
 }
 
 // SendLogs receives entries and batches them to OpenSearch using the bulk API.
-func (o *OpenSearch) SendLogs(ctx context.Context, entries <-chan types.LogEntry) {
+func (o *OpenSearch) SendLogs(ctx context.Context, entries <-chan types.LogEntry) error {
 	var batch []types.LogEntry
 	var batchSize int
+	var sendErr error
 
 	ticker := time.NewTicker(flushInterval)
 	defer ticker.Stop()
@@ -71,7 +71,10 @@ func (o *OpenSearch) SendLogs(ctx context.Context, entries <-chan types.LogEntry
 		if len(batch) == 0 {
 			return
 		}
-		o.send(ctx, batch)
+		// Keep sending the remaining batches; the first error is returned
+		if err := o.send(ctx, batch); err != nil && sendErr == nil {
+			sendErr = err
+		}
 		batch = nil
 		batchSize = 0
 	}
@@ -80,12 +83,12 @@ func (o *OpenSearch) SendLogs(ctx context.Context, entries <-chan types.LogEntry
 		select {
 		case <-ctx.Done():
 			flush()
-			return
+			return ctx.Err()
 
 		case entry, ok := <-entries:
 			if !ok {
 				flush()
-				return
+				return sendErr
 			}
 
 			data, _ := json.Marshal(entry.Data)
@@ -104,7 +107,7 @@ func (o *OpenSearch) SendLogs(ctx context.Context, entries <-chan types.LogEntry
 	}
 }
 
-func (o *OpenSearch) send(ctx context.Context, entries []types.LogEntry) {
+func (o *OpenSearch) send(ctx context.Context, entries []types.LogEntry) error {
 	// Build bulk request body (NDJSON format)
 	var buf bytes.Buffer
 	for _, entry := range entries {
@@ -133,8 +136,7 @@ func (o *OpenSearch) send(ctx context.Context, entries []types.LogEntry) {
 	url := fmt.Sprintf("%s/_bulk", o.endpoint)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &buf)
 	if err != nil {
-		slog.Error("opensearch request failed", "error", err)
-		return
+		return fmt.Errorf("opensearch request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-ndjson")
@@ -145,12 +147,56 @@ func (o *OpenSearch) send(ctx context.Context, entries []types.LogEntry) {
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		slog.Error("opensearch send failed", "error", err)
-		return
+		return fmt.Errorf("opensearch send: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		slog.Error("opensearch error", "status", resp.StatusCode)
+		return fmt.Errorf("opensearch error: status %d", resp.StatusCode)
+	}
+
+	// A bulk request succeeds as a whole even if some documents were rejected
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("opensearch response: %w", err)
+	}
+	return result.err()
+}
+
+// bulkResponse is the reply to a _bulk request, with one item per action.
+type bulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	Status int `json:"status"`
+	Error  struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// err returns an error describing the rejected documents, if any.
+func (r bulkResponse) err() error {
+	var failed int
+	var first bulkResponseItem
+	for _, item := range r.Items {
+		for _, result := range item {
+			if result.Status >= 400 {
+				if failed == 0 {
+					first = result
+				}
+				failed++
+			}
+		}
+	}
+	switch {
+	case failed > 0:
+		return fmt.Errorf("opensearch rejected %d of %d documents: status %d: %s: %s",
+			failed, len(r.Items), first.Status, first.Error.Type, first.Error.Reason)
+	case r.Errors:
+		return fmt.Errorf("opensearch rejected documents")
 	}
+	return nil
 }
\ No newline at end of file
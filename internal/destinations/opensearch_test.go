@@ -46,11 +46,11 @@ func TestOpenSearch_Send(t *testing.T) {
 		}
 
 		entries := []types.LogEntry{
-			{Timestamp: time.Now(), Data: map[string]string{"message": "test1"}},
-			{Timestamp: time.Now(), Data: map[string]string{"message": "test2"}},
+			{Timestamp: time.Now(), Data: map[string]any{"message": "test1"}},
+			{Timestamp: time.Now(), Data: map[string]any{"message": "test2"}},
 		}
 
-		os.send(context.Background(), entries)
+		require.NoError(t, os.send(context.Background(), entries))
 		assert.Len(t, receivedDocs, 2)
 		assert.Equal(t, "test1", receivedDocs[0]["message"])
 		assert.Equal(t, "test2", receivedDocs[1]["message"])
@@ -62,6 +62,7 @@ func TestOpenSearch_Send(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader = r.Header.Get("Authorization")
 			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"errors":false}`))
 		}))
 		defer server.Close()
 
@@ -73,12 +74,57 @@ func TestOpenSearch_Send(t *testing.T) {
 			password: "secret",
 		}
 
-		os.send(context.Background(), []types.LogEntry{
-			{Timestamp: time.Now(), Data: map[string]string{"message": "test"}},
+		err := os.send(context.Background(), []types.LogEntry{
+			{Timestamp: time.Now(), Data: map[string]any{"message": "test"}},
 		})
+		require.NoError(t, err)
 
 		assert.Contains(t, authHeader, "Basic")
 	})
+
+	t.Run("Error status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		os := &OpenSearch{
+			client:   server.Client(),
+			endpoint: server.URL,
+			index:    "test-index",
+		}
+
+		err := os.send(context.Background(), []types.LogEntry{
+			{Timestamp: time.Now(), Data: map[string]any{"message": "test"}},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "429")
+	})
+
+	t.Run("Rejected documents", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"errors":true,"items":[
+				{"index":{"status":201}},
+				{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [elb_status_code]"}}}
+			]}`))
+		}))
+		defer server.Close()
+
+		os := &OpenSearch{
+			client:   server.Client(),
+			endpoint: server.URL,
+			index:    "test-index",
+		}
+
+		err := os.send(context.Background(), []types.LogEntry{
+			{Timestamp: time.Now(), Data: map[string]any{"elb_status_code": "200"}},
+			{Timestamp: time.Now(), Data: map[string]any{"elb_status_code": 200}},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1 of 2 documents")
+		assert.Contains(t, err.Error(), "mapper_parsing_exception")
+	})
 }
 
 func TestOpenSearch_SendLogs(t *testing.T) {
@@ -97,6 +143,7 @@ func TestOpenSearch_SendLogs(t *testing.T) {
 				}
 			}
 			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"errors":false}`))
 		}))
 		defer server.Close()
 
@@ -109,15 +156,15 @@ func TestOpenSearch_SendLogs(t *testing.T) {
 		entries := make(chan types.LogEntry, 2)
 		entries <- types.LogEntry{
 			Timestamp: time.Now(),
-			Data:      map[string]string{"message": "test1"},
+			Data:      map[string]any{"message": "test1"},
 		}
 		entries <- types.LogEntry{
 			Timestamp: time.Now(),
-			Data:      map[string]string{"message": "test2"},
+			Data:      map[string]any{"message": "test2"},
 		}
 		close(entries)
 
-		os.SendLogs(context.Background(), entries)
+		require.NoError(t, os.SendLogs(context.Background(), entries))
 		assert.Equal(t, 2, docCount)
 	})
 }
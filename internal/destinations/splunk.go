@@ -5,7 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
-	"log/slog"
+	"fmt"
 	"net/http"
 	"os"
 	"time"
@@ -29,11 +29,11 @@ type Splunk struct {
 }
 
 type splunkEvent struct {
-	Time       int64             `json:"time"`
-	Source     string            `json:"source,omitempty"`
-	Sourcetype string            `json:"sourcetype,omitempty"`
-	Index      string            `json:"index,omitempty"`
-	Event      map[string]string `json:"event"`
+	Time       json.Number    `json:"time"`
+	Source     string         `json:"source,omitempty"`
+	Sourcetype string         `json:"sourcetype,omitempty"`
+	Index      string         `json:"index,omitempty"`
+	Event      map[string]any `json:"event"`
 }
 
 // NewSplunk creates a Splunk HEC destination from environment configuration.
@@ -69,9 +69,10 @@ func NewSplunk() (*Splunk, error) {
 }
 
 // SendLogs receives entries and batches them to Splunk HEC.
-func (s *Splunk) SendLogs(ctx context.Context, entries <-chan types.LogEntry) {
+func (s *Splunk) SendLogs(ctx context.Context, entries <-chan types.LogEntry) error {
 	var batch []splunkEvent
 	var batchSize int
+	var sendErr error
 
 	ticker := time.NewTicker(flushInterval)
 	defer ticker.Stop()
@@ -80,7 +81,10 @@ func (s *Splunk) SendLogs(ctx context.Context, entries <-chan types.LogEntry) {
 		if len(batch) == 0 {
 			return
 		}
-		s.send(ctx, batch)
+		// Keep sending the remaining batches; the first error is returned
+		if err := s.send(ctx, batch); err != nil && sendErr == nil {
+			sendErr = err
+		}
 		batch = nil
 		batchSize = 0
 	}
@@ -89,16 +93,16 @@ func (s *Splunk) SendLogs(ctx context.Context, entries <-chan types.LogEntry) {
 		select {
 		case <-ctx.Done():
 			flush()
-			return
+			return ctx.Err()
 
 		case entry, ok := <-entries:
 			if !ok {
 				flush()
-				return
+				return sendErr
 			}
 
 			event := splunkEvent{
-				Time:       entry.Timestamp.Unix(),
+				Time:       splunkTime(entry.Timestamp),
 				Source:     s.source,
 				Sourcetype: s.sourcetype,
 				Index:      s.index,
@@ -121,7 +125,7 @@ func (s *Splunk) SendLogs(ctx context.Context, entries <-chan types.LogEntry) {
 	}
 }
 
-func (s *Splunk) send(ctx context.Context, events []splunkEvent) {
+func (s *Splunk) send(ctx context.Context, events []splunkEvent) error {
 	var buf bytes.Buffer
 	for _, e := range events {
 		data, _ := json.Marshal(e)
@@ -130,8 +134,7 @@ func (s *Splunk) send(ctx context.Context, events []splunkEvent) {
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, &buf)
 	if err != nil {
-		slog.Error("splunk request failed", "error", err)
-		return
+		return fmt.Errorf("splunk request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Splunk "+s.token)
@@ -139,12 +142,17 @@ func (s *Splunk) send(ctx context.Context, events []splunkEvent) {
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		slog.Error("splunk send failed", "error", err)
-		return
+		return fmt.Errorf("splunk send: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		slog.Error("splunk error", "status", resp.StatusCode)
+		return fmt.Errorf("splunk error: status %d", resp.StatusCode)
 	}
+	return nil
+}
+
+// splunkTime formats t as HEC event time: epoch seconds with nanoseconds.
+func splunkTime(t time.Time) json.Number {
+	return json.Number(fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond()))
 }
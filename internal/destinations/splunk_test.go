@@ -40,13 +40,30 @@ func TestSplunk_Send(t *testing.T) {
 		}
 
 		events := []splunkEvent{
-			{Time: 1234567890, Event: map[string]string{"message": "test1"}},
-			{Time: 1234567891, Event: map[string]string{"message": "test2"}},
+			{Time: "1234567890.000000000", Event: map[string]any{"message": "test1"}},
+			{Time: "1234567891.000000000", Event: map[string]any{"message": "test2"}},
 		}
 
-		splunk.send(context.Background(), events)
+		require.NoError(t, splunk.send(context.Background(), events))
 		assert.Len(t, receivedEvents, 2)
 	})
+
+	t.Run("Error status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		splunk := &Splunk{
+			client:   server.Client(),
+			endpoint: server.URL,
+			token:    "test-token",
+		}
+
+		err := splunk.send(context.Background(), []splunkEvent{{Time: "1234567890.000000000", Event: map[string]any{"message": "test"}}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "503")
+	})
 }
 
 func TestSplunk_SendLogs(t *testing.T) {
@@ -74,17 +91,36 @@ func TestSplunk_SendLogs(t *testing.T) {
 		entries := make(chan types.LogEntry, 2)
 		entries <- types.LogEntry{
 			Timestamp: time.Now(),
-			Data:      map[string]string{"message": "test1"},
+			Data:      map[string]any{"message": "test1"},
 		}
 		entries <- types.LogEntry{
 			Timestamp: time.Now(),
-			Data:      map[string]string{"message": "test2"},
+			Data:      map[string]any{"message": "test2"},
 		}
 		close(entries)
 
-		splunk.SendLogs(context.Background(), entries)
+		require.NoError(t, splunk.SendLogs(context.Background(), entries))
 		assert.Equal(t, 2, eventCount)
 	})
+
+	t.Run("Failed batch is returned", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		splunk := &Splunk{
+			client:   server.Client(),
+			endpoint: server.URL,
+			token:    "test-token",
+		}
+
+		entries := make(chan types.LogEntry, 1)
+		entries <- types.LogEntry{Timestamp: time.Now(), Data: map[string]any{"message": "test"}}
+		close(entries)
+
+		require.Error(t, splunk.SendLogs(context.Background(), entries))
+	})
 }
 
 func TestNewSplunk(t *testing.T) {
@@ -120,3 +156,8 @@ func TestNewSplunk(t *testing.T) {
 		assert.Equal(t, "main", splunk.index)
 	})
 }
+
+func TestSplunkTime(t *testing.T) {
+	ts := time.Date(2024, 3, 21, 10, 15, 30, 1234, time.UTC)
+	assert.Equal(t, "1711016130.000001234", splunkTime(ts).String())
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log/slog"
 	"time"
 
 	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
@@ -19,23 +18,26 @@ func NewStdout() *Stdout {
 }
 
 // SendLogs writes each entry as JSON to stdout.
-func (s *Stdout) SendLogs(ctx context.Context, entries <-chan types.LogEntry) {
+func (s *Stdout) SendLogs(ctx context.Context, entries <-chan types.LogEntry) error {
+	var sendErr error
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return ctx.Err()
 		case entry, ok := <-entries:
 			if !ok {
-				return
+				return sendErr
 			}
 
 			data, err := json.Marshal(entry.Data)
 			if err != nil {
-				slog.Error("marshal failed", "error", err)
+				if sendErr == nil {
+					sendErr = fmt.Errorf("marshal: %w", err)
+				}
 				continue
 			}
 
-			fmt.Printf("[%s] %s\n", entry.Timestamp.Format(time.RFC3339), data)
+			fmt.Printf("[%s] %s\n", entry.Timestamp.Format(time.RFC3339Nano), data)
 		}
 	}
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStdout_SendLogs(t *testing.T) {
@@ -17,11 +18,11 @@ func TestStdout_SendLogs(t *testing.T) {
 
 	entries <- types.LogEntry{
 		Timestamp: time.Date(2024, time.November, 17, 12, 0, 0, 0, time.UTC),
-		Data:      map[string]string{"message": "test log 1"},
+		Data:      map[string]any{"message": "test log 1"},
 	}
 	entries <- types.LogEntry{
-		Timestamp: time.Date(2024, time.November, 17, 13, 0, 0, 0, time.UTC),
-		Data:      map[string]string{"message": "test log 2"},
+		Timestamp: time.Date(2024, time.November, 17, 13, 0, 0, 500, time.UTC),
+		Data:      map[string]any{"message": "test log 2"},
 	}
 	close(entries)
 
@@ -35,7 +36,7 @@ func TestStdout_SendLogs(t *testing.T) {
 	}()
 
 	dest := NewStdout()
-	dest.SendLogs(context.Background(), entries)
+	err := dest.SendLogs(context.Background(), entries)
 	w.Close()
 
 	output, _ := io.ReadAll(r)
@@ -43,8 +44,9 @@ func TestStdout_SendLogs(t *testing.T) {
 
 	expectedOutput := strings.Join([]string{
 		`[2024-11-17T12:00:00Z] {"message":"test log 1"}`,
-		`[2024-11-17T13:00:00Z] {"message":"test log 2"}`,
+		`[2024-11-17T13:00:00.0000005Z] {"message":"test log 2"}`,
 	}, "\n")
 
+	require.NoError(t, err)
 	assert.Equal(t, expectedOutput, actualOutput)
 }
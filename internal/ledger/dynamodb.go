@@ -0,0 +1,83 @@
+package ledger
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// DynamoDBAPI defines the DynamoDB operations used.
+type DynamoDBAPI interface {
+	GetItem(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoDB stores ledger entries in a DynamoDB table with a string partition
+// key named "id". If LEDGER_TTL is set, entries carry an "expires_at" epoch
+// attribute for use as the table's TTL attribute.
+type DynamoDB struct {
+	client DynamoDBAPI
+	table  string
+	ttl    time.Duration
+}
+
+// NewDynamoDB creates a DynamoDB ledger from environment configuration.
+func NewDynamoDB(sess *session.Session) (*DynamoDB, error) {
+	table, err := requiredEnv("LEDGER_DYNAMODB_TABLE")
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := optionalDuration("LEDGER_TTL")
+	if err != nil {
+		return nil, err
+	}
+
+	return &DynamoDB{
+		client: dynamodb.New(sess),
+		table:  table,
+		ttl:    ttl,
+	}, nil
+}
+
+// Seen reports whether the object version has been recorded.
+func (d *DynamoDB) Seen(obj types.S3ObjectInfo, etag string) (bool, error) {
+	resp, err := d.client.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(d.table),
+		Key:            map[string]*dynamodb.AttributeValue{"id": {S: aws.String(entryID(obj, etag))}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, fmt.Errorf("get ledger item: %w", err)
+	}
+	return len(resp.Item) > 0, nil
+}
+
+// Record stores the object version as forwarded.
+func (d *DynamoDB) Record(obj types.S3ObjectInfo, etag string) error {
+	now := time.Now()
+	item := map[string]*dynamodb.AttributeValue{
+		"id":           {S: aws.String(entryID(obj, etag))},
+		"bucket":       {S: aws.String(obj.Bucket)},
+		"key":          {S: aws.String(obj.Key)},
+		"etag":         {S: aws.String(etag)},
+		"forwarded_at": {S: aws.String(now.UTC().Format(time.RFC3339))},
+	}
+	if d.ttl > 0 {
+		item["expires_at"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Add(d.ttl).Unix(), 10))}
+	}
+
+	_, err := d.client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(d.table),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("put ledger item: %w", err)
+	}
+	return nil
+}
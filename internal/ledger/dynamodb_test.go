@@ -0,0 +1,79 @@
+package ledger
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockDynamoDBClient struct {
+	mock.Mock
+}
+
+func (m *MockDynamoDBClient) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
+}
+
+func (m *MockDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+
+func TestDynamoDB(t *testing.T) {
+	obj := types.S3ObjectInfo{Bucket: "test-bucket", Key: "logs/file1.log.gz"}
+	id := `s3://test-bucket/logs/file1.log.gz@"abc"`
+
+	t.Run("Seen", func(t *testing.T) {
+		mockClient := new(MockDynamoDBClient)
+		mockClient.On("GetItem", mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+			return *input.TableName == "ledger" && *input.Key["id"].S == id && *input.ConsistentRead
+		})).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+		}, nil).Once()
+		mockClient.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{}, nil).Once()
+
+		l := &DynamoDB{client: mockClient, table: "ledger"}
+
+		seen, err := l.Seen(obj, `"abc"`)
+		require.NoError(t, err)
+		assert.True(t, seen)
+
+		seen, err = l.Seen(obj, `"def"`)
+		require.NoError(t, err)
+		assert.False(t, seen)
+	})
+
+	t.Run("Seen error", func(t *testing.T) {
+		mockClient := new(MockDynamoDBClient)
+		mockClient.On("GetItem", mock.Anything).Return((*dynamodb.GetItemOutput)(nil), fmt.Errorf("throttled"))
+
+		l := &DynamoDB{client: mockClient, table: "ledger"}
+
+		_, err := l.Seen(obj, `"abc"`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "throttled")
+	})
+
+	t.Run("Record with TTL", func(t *testing.T) {
+		mockClient := new(MockDynamoDBClient)
+		mockClient.On("PutItem", mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+			return *input.TableName == "ledger" &&
+				*input.Item["id"].S == id &&
+				*input.Item["etag"].S == `"abc"` &&
+				input.Item["expires_at"] != nil
+		})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+		l := &DynamoDB{client: mockClient, table: "ledger", ttl: 24 * time.Hour}
+
+		require.NoError(t, l.Record(obj, `"abc"`))
+		mockClient.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,89 @@
+package ledger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// File stores ledger entries as JSON lines in a local file. Entries are
+// loaded at startup and appended as objects are recorded.
+type File struct {
+	mu   sync.Mutex
+	f    *os.File
+	seen map[string]bool
+}
+
+type fileEntry struct {
+	Bucket      string    `json:"bucket"`
+	Key         string    `json:"key"`
+	ETag        string    `json:"etag"`
+	ForwardedAt time.Time `json:"forwarded_at"`
+}
+
+// NewFile creates a file ledger from environment configuration.
+func NewFile() (*File, error) {
+	path, err := requiredEnv("LEDGER_FILE")
+	if err != nil {
+		return nil, err
+	}
+	return OpenFile(path)
+}
+
+// OpenFile opens (or creates) the ledger file at path.
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open ledger: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var e fileEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s:%d: decode ledger entry: %w", path, line, err)
+		}
+		seen[entryID(types.S3ObjectInfo{Bucket: e.Bucket, Key: e.Key}, e.ETag)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read ledger: %w", err)
+	}
+
+	return &File{f: f, seen: seen}, nil
+}
+
+// Seen reports whether the object version has been recorded.
+func (l *File) Seen(obj types.S3ObjectInfo, etag string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seen[entryID(obj, etag)], nil
+}
+
+// Record appends the object version to the ledger file.
+func (l *File) Record(obj types.S3ObjectInfo, etag string) error {
+	data, err := json.Marshal(fileEntry{
+		Bucket:      obj.Bucket,
+		Key:         obj.Key,
+		ETag:        etag,
+		ForwardedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write ledger: %w", err)
+	}
+	l.seen[entryID(obj, etag)] = true
+	return nil
+}
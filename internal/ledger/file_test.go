@@ -0,0 +1,53 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile(t *testing.T) {
+	obj := types.S3ObjectInfo{Bucket: "test-bucket", Key: "logs/file1.log.gz"}
+
+	t.Run("Record and reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+
+		l, err := OpenFile(path)
+		require.NoError(t, err)
+
+		seen, err := l.Seen(obj, `"abc"`)
+		require.NoError(t, err)
+		assert.False(t, seen)
+
+		require.NoError(t, l.Record(obj, `"abc"`))
+
+		seen, err = l.Seen(obj, `"abc"`)
+		require.NoError(t, err)
+		assert.True(t, seen)
+
+		reopened, err := OpenFile(path)
+		require.NoError(t, err)
+
+		seen, err = reopened.Seen(obj, `"abc"`)
+		require.NoError(t, err)
+		assert.True(t, seen)
+
+		// A new version of the object has a different ETag
+		seen, err = reopened.Seen(obj, `"def"`)
+		require.NoError(t, err)
+		assert.False(t, seen)
+	})
+
+	t.Run("Corrupt ledger file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte("not json\n"), 0o644))
+
+		_, err := OpenFile(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), ":1:")
+	})
+}
@@ -0,0 +1,68 @@
+package ledger
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// Ledger records which S3 objects have been forwarded, so retried or
+// overlapping runs do not send the same object twice. Objects are identified
+// by bucket, key and ETag, so an overwritten object is processed again.
+type Ledger interface {
+	Seen(obj types.S3ObjectInfo, etag string) (bool, error)
+	Record(obj types.S3ObjectInfo, etag string) error
+}
+
+// New creates a ledger from its backend name and environment configuration.
+// An empty name disables the ledger and returns nil.
+func New(backend string, sess *session.Session) (Ledger, error) {
+	var l Ledger
+	var err error
+
+	switch backend {
+	case "":
+		return nil, nil
+	case "dynamodb":
+		l, err = NewDynamoDB(sess)
+	case "file":
+		l, err = NewFile()
+	default:
+		return nil, fmt.Errorf("unknown ledger backend: %q (use 'dynamodb' or 'file')", backend)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s ledger: %w", backend, err)
+	}
+	return l, nil
+}
+
+// entryID identifies an object version in a ledger.
+func entryID(obj types.S3ObjectInfo, etag string) string {
+	return fmt.Sprintf("s3://%s/%s@%s", obj.Bucket, obj.Key, etag)
+}
+
+// requiredEnv returns the value of an environment variable or an error if not set.
+func requiredEnv(key string) (string, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return "", fmt.Errorf("%s required", key)
+	}
+	return v, nil
+}
+
+// optionalDuration parses an optional duration environment variable.
+func optionalDuration(key string) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,37 @@
+package ledger
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		l, err := New("", &session.Session{})
+		require.NoError(t, err)
+		assert.Nil(t, l)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		t.Setenv("LEDGER_FILE", filepath.Join(t.TempDir(), "ledger.jsonl"))
+		l, err := New("file", &session.Session{})
+		require.NoError(t, err)
+		assert.IsType(t, &File{}, l)
+	})
+
+	t.Run("Missing configuration", func(t *testing.T) {
+		t.Setenv("LEDGER_DYNAMODB_TABLE", "")
+		_, err := New("dynamodb", &session.Session{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "LEDGER_DYNAMODB_TABLE")
+	})
+
+	t.Run("Unknown backend", func(t *testing.T) {
+		_, err := New("redis", &session.Session{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,269 @@
+package logprocessor
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// logInterval is how often load balancers publish a log file. The timestamp
+// in a file name is the end of the interval it covers.
+const logInterval = 5 * time.Minute
+
+var (
+	// keyTimeRe matches the end-time component of an ELB log file name, e.g.
+	// 123456789012_elasticloadbalancing_eu-west-1_app.my-lb.abc_20240321T1015Z_10.0.0.1_xyz.log.gz
+	keyTimeRe = regexp.MustCompile(`_(\d{8}T\d{4}Z)_`)
+
+	// keyComponentsRe matches the account, region and load balancer ID in an
+	// ELB log file name (access logs and connection logs).
+	keyComponentsRe = regexp.MustCompile(`^(?:conn_log\.)?(\d{12})_(?:elasticloadbalancing|conn_log)_([a-z0-9-]+)_([^_]+)_`)
+
+	// datePrefixRe matches a prefix that already contains YYYY[/MM[/DD]] components.
+	datePrefixRe = regexp.MustCompile(`(^|/)\d{4}(/\d{2}){0,2}/?$`)
+
+	// regionPrefixRe matches a prefix at the region level of the ELB key
+	// layout, below which the date components follow.
+	regionPrefixRe = regexp.MustCompile(`(^|/)AWSLogs/\d{12}/elasticloadbalancing/[a-z0-9-]+/$`)
+)
+
+// timeLayouts are the accepted formats for --since and --until, in UTC unless
+// the value carries an offset.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// BackfillOptions selects which log files a CLI run processes.
+type BackfillOptions struct {
+	// Since and Until bound the time window (inclusive, exclusive). A zero
+	// value leaves that side of the window open.
+	Since time.Time
+	Until time.Time
+
+	// Accounts, Regions and LoadBalancers filter on the components encoded
+	// in the log file name.
+	Accounts      NameFilter
+	Regions       NameFilter
+	LoadBalancers NameFilter
+
+	// Checkpoint, if set, records progress and skips work completed by an
+	// earlier run.
+	Checkpoint *Checkpoint
+
+	// Archived handles objects in archive storage classes. If nil they are
+	// skipped.
+	Archived *ArchivedObjects
+}
+
+// NameFilter selects values matching any include pattern (or all values if
+// there are none) and no exclude pattern.
+type NameFilter struct {
+	Include []Pattern
+	Exclude []Pattern
+}
+
+// Pattern is a glob (path.Match syntax) or, when wrapped in slashes, a regular expression.
+type Pattern struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+// ParsePatterns parses a filter value: a /regex/ or a comma-separated list of globs.
+func ParsePatterns(s string) ([]Pattern, error) {
+	if len(s) >= 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		re, err := regexp.Compile(s[1 : len(s)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", s, err)
+		}
+		return []Pattern{{raw: s, re: re}}, nil
+	}
+
+	var patterns []Pattern
+	for _, glob := range strings.Split(s, ",") {
+		glob = strings.TrimSpace(glob)
+		if glob == "" {
+			continue
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+		patterns = append(patterns, Pattern{raw: glob})
+	}
+	return patterns, nil
+}
+
+// Match reports whether v matches the pattern. Regular expressions are unanchored.
+func (p Pattern) Match(v string) bool {
+	if p.re != nil {
+		return p.re.MatchString(v)
+	}
+	ok, _ := path.Match(p.raw, v)
+	return ok
+}
+
+func (p Pattern) String() string {
+	return p.raw
+}
+
+func (f NameFilter) active() bool {
+	return len(f.Include) > 0 || len(f.Exclude) > 0
+}
+
+// matches reports whether any of the given forms of a value is selected.
+func (f NameFilter) matches(values ...string) bool {
+	for _, p := range f.Exclude {
+		for _, v := range values {
+			if p.Match(v) {
+				return false
+			}
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, p := range f.Include {
+		for _, v := range values {
+			if p.Match(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseTime parses a --since/--until value.
+func ParseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use RFC 3339, YYYY-MM-DDTHH:MM or YYYY-MM-DD)", s)
+}
+
+func (o BackfillOptions) validate() error {
+	if !o.Since.IsZero() && !o.Until.IsZero() && !o.Since.Before(o.Until) {
+		return fmt.Errorf("since (%s) must be before until (%s)", o.Since.Format(time.RFC3339), o.Until.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (o BackfillOptions) hasTimeWindow() bool {
+	return !o.Since.IsZero() || !o.Until.IsZero()
+}
+
+// matches reports whether the log file at key should be processed.
+func (o BackfillOptions) matches(key string) bool {
+	return o.matchesComponents(key) && o.matchesTimeWindow(key)
+}
+
+func (o BackfillOptions) matchesComponents(key string) bool {
+	if !o.Accounts.active() && !o.Regions.active() && !o.LoadBalancers.active() {
+		return true
+	}
+
+	m := keyComponentsRe.FindStringSubmatch(path.Base(key))
+	if m == nil {
+		return false
+	}
+	account, region, lbID := m[1], m[2], m[3]
+
+	// Load balancer IDs look like app.my-lb.1234567890abcdef; also match
+	// patterns against the ID without its hash so "app.my-lb" selects it
+	lbName := lbID
+	if i := strings.LastIndex(lbID, "."); i > 0 {
+		lbName = lbID[:i]
+	}
+
+	return o.Accounts.matches(account) &&
+		o.Regions.matches(region) &&
+		o.LoadBalancers.matches(lbID, lbName)
+}
+
+func (o BackfillOptions) matchesTimeWindow(key string) bool {
+	if !o.hasTimeWindow() {
+		return true
+	}
+
+	end, ok := keyTime(key)
+	if !ok {
+		return false
+	}
+
+	// The file covers (end-logInterval, end]; keep it if that overlaps the window
+	if !o.Since.IsZero() && !end.After(o.Since) {
+		return false
+	}
+	if !o.Until.IsZero() && !end.Add(-logInterval).Before(o.Until) {
+		return false
+	}
+	return true
+}
+
+// prefixes expands a listing prefix into the date prefixes of the ELB key
+// layout (YYYY/MM/DD/) that cover the time window. Whole months and years are
+// collapsed into a single prefix. Without a lower bound the prefix is listed as
+// is. With one the prefix must be at the region level, as the date components
+// would otherwise be appended to the account or region. Without an upper bound
+// the window ends at the time recorded in the checkpoint, so a resumed run
+// lists the same prefixes.
+func (o BackfillOptions) prefixes(prefix string) ([]string, error) {
+	if o.Since.IsZero() {
+		return []string{prefix}, nil
+	}
+	if datePrefixRe.MatchString(prefix) {
+		return nil, fmt.Errorf("prefix %q already contains a date; point it at the region level", prefix)
+	}
+	if prefix != "" && prefix[len(prefix)-1] != '/' {
+		prefix += "/"
+	}
+	if !regionPrefixRe.MatchString(prefix) {
+		return nil, fmt.Errorf("prefix %q is not at the region level; use .../AWSLogs/<account>/elasticloadbalancing/<region>/ with --since", prefix)
+	}
+
+	until := o.Until
+	if until.IsZero() {
+		until = o.Checkpoint.until(time.Now())
+	}
+
+	// Files are named after the end of their interval, which can fall on the next day
+	first := truncateDay(o.Since.UTC())
+	last := truncateDay(until.UTC().Add(logInterval))
+
+	var result []string
+	for day := first; !day.After(last); {
+		switch {
+		case day.YearDay() == 1 && !day.AddDate(1, 0, -1).After(last):
+			result = append(result, path.Join(prefix, day.Format("2006"))+"/")
+			day = day.AddDate(1, 0, 0)
+		case day.Day() == 1 && !day.AddDate(0, 1, -1).After(last):
+			result = append(result, path.Join(prefix, day.Format("2006/01"))+"/")
+			day = day.AddDate(0, 1, 0)
+		default:
+			result = append(result, path.Join(prefix, day.Format("2006/01/02"))+"/")
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+	return result, nil
+}
+
+// keyTime extracts the interval end time from an ELB log file name.
+func keyTime(key string) (time.Time, bool) {
+	m := keyTimeRe.FindStringSubmatch(path.Base(key))
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102T1504Z", m[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,273 @@
+package logprocessor
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const regionPrefix = "AWSLogs/123456789012/elasticloadbalancing/eu-west-1/"
+
+// elbKey builds an ELB log object key for a file whose interval ends at end.
+func elbKey(end string) string {
+	t, _ := time.Parse("20060102T1504Z", end)
+	return regionPrefix + t.Format("2006/01/02/") +
+		"123456789012_elasticloadbalancing_eu-west-1_app.prod-api.1234567890abcdef_" + end + "_10.0.0.1_abc123.log.gz"
+}
+
+func mustParseTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	ts, err := ParseTime(s)
+	require.NoError(t, err)
+	return ts
+}
+
+func TestParseTime(t *testing.T) {
+	t.Run("Accepted layouts", func(t *testing.T) {
+		want := time.Date(2024, 3, 19, 14, 0, 0, 0, time.UTC)
+		assert.Equal(t, want, mustParseTime(t, "2024-03-19T14:00:00Z"))
+		assert.Equal(t, want, mustParseTime(t, "2024-03-19T14:00"))
+		assert.Equal(t, want.Truncate(24*time.Hour), mustParseTime(t, "2024-03-19"))
+	})
+
+	t.Run("Invalid time", func(t *testing.T) {
+		_, err := ParseTime("last tuesday")
+		require.Error(t, err)
+	})
+}
+
+func TestBackfillPrefixes(t *testing.T) {
+	t.Run("Single day", func(t *testing.T) {
+		opts := BackfillOptions{
+			Since: mustParseTime(t, "2024-03-19T14:00"),
+			Until: mustParseTime(t, "2024-03-19T16:00"),
+		}
+		prefixes, err := opts.prefixes(regionPrefix)
+		require.NoError(t, err)
+		assert.Equal(t, []string{regionPrefix + "2024/03/19/"}, prefixes)
+	})
+
+	t.Run("Window ending at midnight includes next day", func(t *testing.T) {
+		opts := BackfillOptions{
+			Since: mustParseTime(t, "2024-03-19T22:00"),
+			Until: mustParseTime(t, "2024-03-20"),
+		}
+		prefixes, err := opts.prefixes(regionPrefix)
+		require.NoError(t, err)
+		assert.Equal(t, []string{regionPrefix + "2024/03/19/", regionPrefix + "2024/03/20/"}, prefixes)
+	})
+
+	t.Run("Whole months and years are collapsed", func(t *testing.T) {
+		opts := BackfillOptions{
+			Since: mustParseTime(t, "2022-12-31"),
+			Until: mustParseTime(t, "2024-02-02T12:00"),
+		}
+		prefixes, err := opts.prefixes(regionPrefix)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			regionPrefix + "2022/12/31/",
+			regionPrefix + "2023/",
+			regionPrefix + "2024/01/",
+			regionPrefix + "2024/02/01/",
+			regionPrefix + "2024/02/02/",
+		}, prefixes)
+	})
+
+	t.Run("Missing trailing slash is added", func(t *testing.T) {
+		opts := BackfillOptions{
+			Since: mustParseTime(t, "2024-03-19T14:00"),
+			Until: mustParseTime(t, "2024-03-19T16:00"),
+		}
+		prefixes, err := opts.prefixes("AWSLogs/123456789012/elasticloadbalancing/eu-west-1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{regionPrefix + "2024/03/19/"}, prefixes)
+	})
+
+	t.Run("Prefix with date is rejected", func(t *testing.T) {
+		opts := BackfillOptions{Since: mustParseTime(t, "2024-03-19")}
+		_, err := opts.prefixes(regionPrefix + "2024/03/")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already contains a date")
+	})
+
+	t.Run("Prefix above the region level is rejected", func(t *testing.T) {
+		opts := BackfillOptions{Since: mustParseTime(t, "2024-03-19")}
+		for _, prefix := range []string{"", "AWSLogs/", "AWSLogs/123456789012/elasticloadbalancing/"} {
+			_, err := opts.prefixes(prefix)
+			require.Error(t, err, prefix)
+			assert.Contains(t, err.Error(), "region level")
+		}
+	})
+
+	t.Run("Prefix below a custom bucket prefix", func(t *testing.T) {
+		opts := BackfillOptions{Since: mustParseTime(t, "2024-03-19"), Until: mustParseTime(t, "2024-03-19T12:00")}
+		prefixes, err := opts.prefixes("prod/" + regionPrefix)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"prod/" + regionPrefix + "2024/03/19/"}, prefixes)
+	})
+
+	t.Run("No lower bound keeps prefix", func(t *testing.T) {
+		opts := BackfillOptions{Until: mustParseTime(t, "2024-03-19")}
+		prefixes, err := opts.prefixes(regionPrefix)
+		require.NoError(t, err)
+		assert.Equal(t, []string{regionPrefix}, prefixes)
+	})
+}
+
+func TestBackfillMatches(t *testing.T) {
+	opts := BackfillOptions{
+		Since: mustParseTime(t, "2024-03-19T14:00"),
+		Until: mustParseTime(t, "2024-03-19T16:00"),
+	}
+
+	assert.False(t, opts.matches(elbKey("20240319T1400Z")), "interval ends at since")
+	assert.True(t, opts.matches(elbKey("20240319T1405Z")))
+	assert.True(t, opts.matches(elbKey("20240319T1600Z")))
+	assert.True(t, opts.matches(elbKey("20240319T1604Z")), "interval starts before until")
+	assert.False(t, opts.matches(elbKey("20240319T1605Z")), "interval starts at until")
+	assert.False(t, opts.matches(regionPrefix+"2024/03/19/unrelated.txt"))
+
+	assert.True(t, BackfillOptions{}.matches(regionPrefix+"2024/03/19/unrelated.txt"))
+}
+
+func TestHandleS3URLTimeWindow(t *testing.T) {
+	t.Run("Lists date prefixes and filters by key time", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		listing := map[string][]string{
+			"2024/03/19/": {elbKey("20240319T2300Z"), elbKey("20240319T2355Z")},
+			"2024/03/20/": {elbKey("20240320T0000Z"), elbKey("20240320T0005Z")},
+		}
+		for day, keys := range listing {
+			var contents []*s3.Object
+			for _, key := range keys {
+				contents = append(contents, &s3.Object{Key: aws.String(key)})
+			}
+			mockS3.On("ListObjectsV2", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+				return *input.Prefix == regionPrefix+day
+			})).Return(&s3.ListObjectsV2Output{
+				Contents:    contents,
+				IsTruncated: aws.Bool(false),
+			}, nil).Once()
+		}
+
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+
+		opts := BackfillOptions{
+			Since: mustParseTime(t, "2024-03-19T23:30"),
+			Until: mustParseTime(t, "2024-03-20"),
+		}
+		err := lp.HandleS3URL(context.Background(), "s3://my-bucket/"+regionPrefix, opts)
+		require.NoError(t, err)
+
+		assert.Len(t, mockDest.Entries(), 10)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Since after until", func(t *testing.T) {
+		lp := NewWithDeps(new(MockS3API), nil, []destinations.Destination{&MockDestination{}})
+
+		opts := BackfillOptions{
+			Since: mustParseTime(t, "2024-03-20"),
+			Until: mustParseTime(t, "2024-03-19"),
+		}
+		err := lp.HandleS3URL(context.Background(), "s3://my-bucket/"+regionPrefix, opts)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be before")
+	})
+}
+
+func mustParsePatterns(t *testing.T, s string) []Pattern {
+	t.Helper()
+	patterns, err := ParsePatterns(s)
+	require.NoError(t, err)
+	return patterns
+}
+
+func TestParsePatterns(t *testing.T) {
+	t.Run("Glob list", func(t *testing.T) {
+		patterns := mustParsePatterns(t, "eu-*, us-east-1")
+		require.Len(t, patterns, 2)
+		assert.True(t, patterns[0].Match("eu-west-1"))
+		assert.False(t, patterns[0].Match("us-east-1"))
+		assert.True(t, patterns[1].Match("us-east-1"))
+	})
+
+	t.Run("Regex", func(t *testing.T) {
+		patterns := mustParsePatterns(t, "/^app\\.prod-(api|web)$/")
+		require.Len(t, patterns, 1)
+		assert.True(t, patterns[0].Match("app.prod-web"))
+		assert.False(t, patterns[0].Match("app.staging-web"))
+	})
+
+	t.Run("Invalid patterns", func(t *testing.T) {
+		_, err := ParsePatterns("/(/")
+		require.Error(t, err)
+
+		_, err = ParsePatterns("[")
+		require.Error(t, err)
+	})
+}
+
+func TestBackfillMatchesComponents(t *testing.T) {
+	apiKey := elbKey("20240319T1405Z")
+	webKey := regionPrefix + "2024/03/19/123456789012_elasticloadbalancing_eu-west-1_app.prod-web.fedcba0987654321_20240319T1405Z_10.0.0.1_abc123.log.gz"
+	usKey := "AWSLogs/210987654321/elasticloadbalancing/us-east-1/2024/03/19/210987654321_elasticloadbalancing_us-east-1_net.prod-nlb.1234567890abcdef_20240319T1405Z_10.0.0.1_abc123.log.gz"
+	connKey := regionPrefix + "2024/03/19/conn_log.123456789012_conn_log_eu-west-1_app.prod-api.1234567890abcdef_20240319T1405Z_10.0.0.1_abc123.log.gz"
+
+	t.Run("Load balancer by name", func(t *testing.T) {
+		opts := BackfillOptions{LoadBalancers: NameFilter{Include: mustParsePatterns(t, "app.prod-api")}}
+		assert.True(t, opts.matches(apiKey))
+		assert.True(t, opts.matches(connKey))
+		assert.False(t, opts.matches(webKey))
+		assert.False(t, opts.matches(usKey))
+	})
+
+	t.Run("Region and exclude", func(t *testing.T) {
+		opts := BackfillOptions{
+			Regions:       NameFilter{Include: mustParsePatterns(t, "eu-*")},
+			LoadBalancers: NameFilter{Exclude: mustParsePatterns(t, "*.prod-web")},
+		}
+		assert.True(t, opts.matches(apiKey))
+		assert.False(t, opts.matches(webKey))
+		assert.False(t, opts.matches(usKey))
+	})
+
+	t.Run("Account", func(t *testing.T) {
+		opts := BackfillOptions{Accounts: NameFilter{Exclude: mustParsePatterns(t, "123456789012")}}
+		assert.False(t, opts.matches(apiKey))
+		assert.True(t, opts.matches(usKey))
+	})
+
+	t.Run("Unrecognised key is skipped when filtering", func(t *testing.T) {
+		opts := BackfillOptions{Regions: NameFilter{Include: mustParsePatterns(t, "eu-west-1")}}
+		assert.False(t, opts.matches("logs/file1.log.gz"))
+	})
+
+	t.Run("Combined with time window", func(t *testing.T) {
+		opts := BackfillOptions{
+			Since:         mustParseTime(t, "2024-03-19T14:00"),
+			Until:         mustParseTime(t, "2024-03-19T16:00"),
+			LoadBalancers: NameFilter{Include: mustParsePatterns(t, "app.prod-api")},
+		}
+		assert.True(t, opts.matches(apiKey))
+		assert.False(t, opts.matches(webKey))
+		assert.False(t, opts.matches(elbKey("20240319T1705Z")))
+	})
+}
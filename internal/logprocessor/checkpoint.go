@@ -0,0 +1,252 @@
+package logprocessor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// checkpointSaveInterval limits how often progress is written to disk.
+const checkpointSaveInterval = time.Second
+
+// Checkpoint records backfill progress in a file so an interrupted run can
+// be resumed. For every listed S3 prefix it keeps the last key up to which
+// all objects are done, so listing resumes after it, plus the set of objects
+// completed beyond that point. It also keeps the end of an open time window,
+// since listing prefixes depend on it. A nil *Checkpoint records nothing.
+type Checkpoint struct {
+	path string
+
+	mu        sync.Mutex
+	listings  map[string]*listingProgress
+	completed map[string]bool
+	openUntil time.Time
+	lastSave  time.Time
+}
+
+// listingProgress tracks the objects of one prefix in listing (key) order.
+type listingProgress struct {
+	after   string
+	pending []string
+	done    map[string]bool
+}
+
+type checkpointFile struct {
+	Until     time.Time         `json:"until,omitzero"`
+	Listings  map[string]string `json:"listings"`
+	Completed []string          `json:"completed"`
+}
+
+// OpenCheckpoint opens the checkpoint file at path. With resume, progress
+// recorded by an earlier run is loaded; otherwise it is discarded.
+func OpenCheckpoint(path string, resume bool) (*Checkpoint, error) {
+	c := &Checkpoint{
+		path:      path,
+		listings:  make(map[string]*listingProgress),
+		completed: make(map[string]bool),
+	}
+	if !resume {
+		return c, c.Save()
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("no checkpoint to resume from, starting over", "path", path)
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+
+	var f checkpointFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("decode checkpoint %s: %w", path, err)
+	}
+	for id, after := range f.Listings {
+		c.listings[id] = &listingProgress{after: after, done: make(map[string]bool)}
+	}
+	for _, id := range f.Completed {
+		c.completed[id] = true
+	}
+	c.openUntil = f.Until
+
+	slog.Info("resuming from checkpoint", "path", path, "listings", len(f.Listings), "completed", len(f.Completed))
+	return c, nil
+}
+
+// until returns the end of a time window left open: the one recorded by the
+// run that created the checkpoint, or now, which is recorded for runs
+// resuming from it.
+func (c *Checkpoint) until(now time.Time) time.Time {
+	if c == nil {
+		return now
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.openUntil.IsZero() {
+		c.openUntil = now
+		if err := c.save(); err != nil {
+			slog.Error("checkpoint save failed", "path", c.path, "error", err)
+		}
+	}
+	return c.openUntil
+}
+
+// startAfter returns the key after which listing of prefix resumes.
+func (c *Checkpoint) startAfter(bucket, prefix string) string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l, ok := c.listings[listingID(bucket, prefix)]; ok {
+		return l.after
+	}
+	return ""
+}
+
+// listed registers a listed object as pending and reports whether it was
+// already completed by an earlier run.
+func (c *Checkpoint) listed(bucket, prefix, key string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l := c.listing(bucket, prefix)
+	l.pending = append(l.pending, key)
+	return c.completed[objectID(bucket, key)]
+}
+
+// done marks a listed object as completed or skipped and advances the
+// listing position past every leading object that is done.
+func (c *Checkpoint) done(bucket, prefix, key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.completed[objectID(bucket, key)] = true
+
+	l := c.listing(bucket, prefix)
+	l.done[key] = true
+	for len(l.pending) > 0 && l.done[l.pending[0]] {
+		head := l.pending[0]
+		l.after = head
+		l.pending = l.pending[1:]
+		delete(l.done, head)
+		delete(c.completed, objectID(bucket, head))
+	}
+
+	c.saveThrottled()
+}
+
+// isCompleted reports whether a local file was completed by an earlier run.
+func (c *Checkpoint) isCompleted(path string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.completed[path]
+}
+
+// markCompleted records a local file as completed.
+func (c *Checkpoint) markCompleted(path string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.completed[path] = true
+	c.saveThrottled()
+}
+
+// Save writes the checkpoint file.
+func (c *Checkpoint) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+func (c *Checkpoint) listing(bucket, prefix string) *listingProgress {
+	id := listingID(bucket, prefix)
+	l, ok := c.listings[id]
+	if !ok {
+		l = &listingProgress{done: make(map[string]bool)}
+		c.listings[id] = l
+	}
+	return l
+}
+
+func (c *Checkpoint) saveThrottled() {
+	if time.Since(c.lastSave) < checkpointSaveInterval {
+		return
+	}
+	if err := c.save(); err != nil {
+		slog.Error("checkpoint save failed", "path", c.path, "error", err)
+	}
+}
+
+func (c *Checkpoint) save() error {
+	f := checkpointFile{
+		Until:     c.openUntil,
+		Listings:  make(map[string]string, len(c.listings)),
+		Completed: make([]string, 0, len(c.completed)),
+	}
+	for id, l := range c.listings {
+		if l.after != "" {
+			f.Listings[id] = l.after
+		}
+	}
+	for id := range c.completed {
+		f.Completed = append(f.Completed, id)
+	}
+	sort.Strings(f.Completed)
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated checkpoint
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+
+	c.lastSave = time.Now()
+	return nil
+}
+
+func listingID(bucket, prefix string) string {
+	return "s3://" + bucket + "/" + prefix
+}
+
+func objectID(bucket, key string) string {
+	return "s3://" + bucket + "/" + key
+}
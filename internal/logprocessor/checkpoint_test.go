@@ -0,0 +1,200 @@
+package logprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func readCheckpoint(t *testing.T, path string) checkpointFile {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var f checkpointFile
+	require.NoError(t, json.Unmarshal(data, &f))
+	return f
+}
+
+func TestCheckpoint(t *testing.T) {
+	t.Run("Listing position advances past leading done objects", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint.json")
+		cp, err := OpenCheckpoint(path, false)
+		require.NoError(t, err)
+
+		for _, key := range []string{"logs/a", "logs/b", "logs/c"} {
+			assert.False(t, cp.listed("bucket", "logs/", key))
+		}
+
+		cp.done("bucket", "logs/", "logs/b")
+		require.NoError(t, cp.Save())
+		f := readCheckpoint(t, path)
+		assert.Empty(t, f.Listings)
+		assert.Equal(t, []string{"s3://bucket/logs/b"}, f.Completed)
+
+		cp.done("bucket", "logs/", "logs/a")
+		require.NoError(t, cp.Save())
+		f = readCheckpoint(t, path)
+		assert.Equal(t, map[string]string{"s3://bucket/logs/": "logs/b"}, f.Listings)
+		assert.Empty(t, f.Completed)
+	})
+
+	t.Run("Resume loads progress", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint.json")
+		data := `{"listings":{"s3://bucket/logs/":"logs/b"},"completed":["s3://bucket/logs/d","/tmp/local.log"]}`
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+
+		cp, err := OpenCheckpoint(path, true)
+		require.NoError(t, err)
+		assert.Equal(t, "logs/b", cp.startAfter("bucket", "logs/"))
+		assert.Equal(t, "", cp.startAfter("bucket", "other/"))
+		assert.False(t, cp.listed("bucket", "logs/", "logs/c"))
+		assert.True(t, cp.listed("bucket", "logs/", "logs/d"))
+		assert.True(t, cp.isCompleted("/tmp/local.log"))
+	})
+
+	t.Run("Without resume progress is discarded", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"listings":{"s3://bucket/logs/":"logs/b"}}`), 0o644))
+
+		cp, err := OpenCheckpoint(path, false)
+		require.NoError(t, err)
+		assert.Equal(t, "", cp.startAfter("bucket", "logs/"))
+		assert.Empty(t, readCheckpoint(t, path).Listings)
+	})
+
+	t.Run("Resume without checkpoint file starts over", func(t *testing.T) {
+		cp, err := OpenCheckpoint(filepath.Join(t.TempDir(), "missing.json"), true)
+		require.NoError(t, err)
+		assert.Equal(t, "", cp.startAfter("bucket", "logs/"))
+	})
+
+	t.Run("Corrupt checkpoint", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint.json")
+		require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+
+		_, err := OpenCheckpoint(path, true)
+		require.Error(t, err)
+	})
+
+	t.Run("Open window end is recorded once", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint.json")
+		cp, err := OpenCheckpoint(path, false)
+		require.NoError(t, err)
+
+		first := time.Date(2024, 3, 31, 23, 57, 0, 0, time.UTC)
+		assert.Equal(t, first, cp.until(first))
+		assert.Equal(t, first, cp.until(first.Add(time.Hour)))
+		assert.Equal(t, first, readCheckpoint(t, path).Until)
+
+		resumed, err := OpenCheckpoint(path, true)
+		require.NoError(t, err)
+		assert.Equal(t, first, resumed.until(first.AddDate(0, 1, 0)))
+	})
+
+	t.Run("Nil checkpoint records nothing", func(t *testing.T) {
+		var cp *Checkpoint
+		assert.False(t, cp.listed("bucket", "logs/", "logs/a"))
+		cp.done("bucket", "logs/", "logs/a")
+		assert.NoError(t, cp.Save())
+	})
+}
+
+func TestHandleS3URLResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	keys := []string{"logs/file1.log.gz", "logs/file2.log.gz", "logs/file3.log.gz"}
+
+	// An interrupted run completed file1 and file3, but not file2
+	data := `{"listings":{"s3://my-bucket/logs/":"logs/file1.log.gz"},"completed":["s3://my-bucket/logs/file3.log.gz"]}`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+
+	mockS3 := new(MockS3API)
+	mockDest := &MockDestination{}
+
+	mockS3.On("ListObjectsV2", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return input.StartAfter != nil && *input.StartAfter == keys[0]
+	})).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String(keys[1])},
+			{Key: aws.String(keys[2])},
+		},
+		IsTruncated: aws.Bool(false),
+	}, nil).Once()
+	mockS3.On("GetObject", keyIs(keys[1])).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(loadTestData(t)),
+	}, nil).Once()
+
+	cp, err := OpenCheckpoint(path, true)
+	require.NoError(t, err)
+
+	lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+	err = lp.HandleS3URL(context.Background(), "s3://my-bucket/logs/", BackfillOptions{Checkpoint: cp})
+	require.NoError(t, err)
+	require.NoError(t, cp.Save())
+
+	mockS3.AssertExpectations(t)
+	assert.Len(t, mockDest.Entries(), 5)
+
+	f := readCheckpoint(t, path)
+	assert.Equal(t, keys[2], f.Listings["s3://my-bucket/logs/"])
+	assert.Empty(t, f.Completed)
+}
+
+func TestHandleS3URLResumeOpenWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	// The first run started just before a month boundary, so it listed day
+	// prefixes that a window ending now would collapse into months and years
+	day1, day2 := regionPrefix+"2024/03/31/", regionPrefix+"2024/04/01/"
+	data := fmt.Sprintf(`{"until":"2024-03-31T23:57:00Z","listings":{"s3://my-bucket/%s":"%sa.log.gz"}}`, day1, day1)
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+
+	mockS3 := new(MockS3API)
+	mockS3.On("ListObjectsV2", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == day1 && aws.StringValue(input.StartAfter) == day1+"a.log.gz"
+	})).Return(&s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}, nil).Once()
+	mockS3.On("ListObjectsV2", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == day2 && input.StartAfter == nil
+	})).Return(&s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}, nil).Once()
+
+	cp, err := OpenCheckpoint(path, true)
+	require.NoError(t, err)
+
+	lp := NewWithDeps(mockS3, nil, []destinations.Destination{&MockDestination{}})
+	opts := BackfillOptions{Since: mustParseTime(t, "2024-03-31"), Checkpoint: cp}
+	require.NoError(t, lp.HandleS3URL(context.Background(), "s3://my-bucket/"+regionPrefix, opts))
+	mockS3.AssertExpectations(t)
+}
+
+func TestHandleInputResume(t *testing.T) {
+	dir := writeLogFiles(t)
+	done := filepath.Join(dir, "2024", "03", "21", "a.log.gz")
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	data, err := json.Marshal(checkpointFile{Completed: []string{done}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	cp, err := OpenCheckpoint(path, true)
+	require.NoError(t, err)
+
+	mockDest := &MockDestination{}
+	lp := NewWithDeps(nil, nil, []destinations.Destination{mockDest})
+	err = lp.HandleInput(context.Background(), dir, BackfillOptions{Checkpoint: cp})
+	require.NoError(t, err)
+	require.NoError(t, cp.Save())
+
+	assert.Len(t, mockDest.Entries(), 5)
+	assert.Len(t, readCheckpoint(t, path).Completed, 3)
+}
@@ -0,0 +1,133 @@
+package logprocessor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// CloudFront standard log fields in order. Files name their fields in a
+// #Fields header, which takes precedence.
+// https://docs.aws.amazon.com/AmazonCloudFront/latest/DeveloperGuide/standard-logs-reference.html
+var cloudFrontFields = []string{
+	"date",
+	"time",
+	"x-edge-location",
+	"sc-bytes",
+	"c-ip",
+	"cs-method",
+	"cs(Host)",
+	"cs-uri-stem",
+	"sc-status",
+	"cs(Referer)",
+	"cs(User-Agent)",
+	"cs-uri-query",
+	"cs(Cookie)",
+	"x-edge-result-type",
+	"x-edge-request-id",
+	"x-host-header",
+	"cs-protocol",
+	"cs-bytes",
+	"time-taken",
+	"x-forwarded-for",
+	"ssl-protocol",
+	"ssl-cipher",
+	"x-edge-response-result-type",
+	"cs-protocol-version",
+	"fle-status",
+	"fle-encrypted-fields",
+	"c-port",
+	"time-to-first-byte",
+	"x-edge-detailed-result-type",
+	"sc-content-type",
+	"sc-content-len",
+	"sc-range-start",
+	"sc-range-end",
+}
+
+const (
+	cloudFrontTimeLayout = "2006-01-02 15:04:05"
+	maxLineSize          = 1 << 20
+)
+
+// cloudFrontParser parses CloudFront standard logs: tab-separated W3C
+// extended log format with #Version and #Fields headers.
+type cloudFrontParser struct {
+	fields *FieldFilter
+}
+
+func newCloudFrontParser(fieldConfig string, opts FieldOptions) (Parser, error) {
+	fields, err := NewFieldFilter(LBTypeCloudFront, fieldConfig, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &cloudFrontParser{fields: fields}, nil
+}
+
+func (p *cloudFrontParser) Parse(r io.Reader, out chan<- types.LogEntry, reject RejectFunc) (ParseStats, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	stats := ParseStats{Type: LBTypeCloudFront}
+
+	names := cloudFrontFields
+	dateIdx, timeIdx := 0, 1
+
+	for line := 1; scanner.Scan(); line++ {
+		raw := scanner.Text()
+		if raw == "" {
+			continue
+		}
+		if strings.HasPrefix(raw, "#") {
+			if header, ok := strings.CutPrefix(raw, "#Fields:"); ok {
+				names = strings.Fields(header)
+				dateIdx, timeIdx = -1, -1
+				for i, name := range names {
+					switch name {
+					case "date":
+						dateIdx = i
+					case "time":
+						timeIdx = i
+					}
+				}
+				if dateIdx < 0 || timeIdx < 0 {
+					return stats, fmt.Errorf("#Fields header has no date and time fields")
+				}
+				slog.Debug("log format", "type", LBTypeCloudFront, "fields", len(names))
+			}
+			continue
+		}
+
+		record := strings.Split(raw, "\t")
+		ts, err := cloudFrontTime(record, dateIdx, timeIdx)
+		if err != nil {
+			if err := stats.reject(reject, line, raw, err); err != nil {
+				return stats, err
+			}
+			continue
+		}
+
+		stats.count(len(record), len(names))
+		out <- types.LogEntry{Data: namedData(p.fields, names, record), Timestamp: ts}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("read record: %w", err)
+	}
+	return stats, nil
+}
+
+// cloudFrontTime returns the time of a record from its date and time fields.
+func cloudFrontTime(record []string, dateIdx, timeIdx int) (time.Time, error) {
+	if len(record) <= max(dateIdx, timeIdx) {
+		return time.Time{}, fmt.Errorf("record too short: need at least %d fields for timestamp, got %d", max(dateIdx, timeIdx)+1, len(record))
+	}
+	ts, err := time.Parse(cloudFrontTimeLayout, record[dateIdx]+" "+record[timeIdx])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse timestamp: %w", err)
+	}
+	return ts, nil
+}
@@ -0,0 +1,51 @@
+package logprocessor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cloudFrontLog = "#Version: 1.0\n" +
+	"#Fields: date time x-edge-location sc-bytes c-ip cs-method cs(Host) cs-uri-stem sc-status cs(Referer) cs(User-Agent) cs-uri-query cs(Cookie) x-edge-result-type x-edge-request-id x-host-header cs-protocol cs-bytes time-taken x-forwarded-for ssl-protocol ssl-cipher x-edge-response-result-type cs-protocol-version fle-status fle-encrypted-fields c-port time-to-first-byte x-edge-detailed-result-type sc-content-type sc-content-len sc-range-start sc-range-end\n" +
+	"2019-12-04\t21:02:31\tLAX1\t392\t192.0.2.100\tGET\td111111abcdef8.cloudfront.net\t/index.html\t200\t-\tMozilla/5.0%20(Windows%20NT%2010.0;%20Win64;%20x64)\t-\t-\tHit\tSOX4xwn4XV6Q4rgb7XiVGOHms_BGlTAC4KyHmureZmBNrjGdRLiNIQ==\td111111abcdef8.cloudfront.net\thttps\t23\t0.001\t-\tTLSv1.2\tECDHE-RSA-AES128-GCM-SHA256\tHit\tHTTP/2.0\t-\t-\t11040\t0.001\tHit\ttext/html\t78\t-\t-\n" +
+	"2019-12-04\t21:02:31\tLAX1\t392\t192.0.2.100\tGET\td111111abcdef8.cloudfront.net\t/favicon.ico\t502\t-\tcurl/7.68.0\t-\t-\tError\tk6WGMNkEzR5BEM_SaF47gjtX9zBDO2m349OY2an0QPEaUum1ZOLrow==\td111111abcdef8.cloudfront.net\thttps\t23\t0.002\t-\tTLSv1.2\tECDHE-RSA-AES128-GCM-SHA256\tError\tHTTP/2.0\t-\t-\t11040\t0.002\tError\ttext/html\t78\t-\t-\n"
+
+func TestCloudFrontParser(t *testing.T) {
+	t.Run("Standard log", func(t *testing.T) {
+		parser, err := NewParser(LBTypeCloudFront, "", FieldOptions{})
+		require.NoError(t, err)
+
+		entries, err := parseAll(t, parser, cloudFrontLog)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		assert.Equal(t, time.Date(2019, 12, 4, 21, 2, 31, 0, time.UTC), entries[0].Timestamp)
+		assert.Equal(t, "/index.html", entries[0].Data["cs-uri-stem"])
+		assert.Equal(t, "d111111abcdef8.cloudfront.net", entries[0].Data["cs(Host)"])
+		assert.Equal(t, "502", entries[1].Data["sc-status"])
+		assert.Len(t, entries[0].Data, 33)
+	})
+
+	t.Run("Fields follow the header", func(t *testing.T) {
+		parser, err := NewParser(LBTypeCloudFront, "c-ip,sc-status", FieldOptions{})
+		require.NoError(t, err)
+
+		log := "#Version: 1.0\n#Fields: time date sc-status c-ip\n21:02:31\t2019-12-04\t200\t192.0.2.100\n"
+		entries, err := parseAll(t, parser, log)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, time.Date(2019, 12, 4, 21, 2, 31, 0, time.UTC), entries[0].Timestamp)
+		assert.Equal(t, map[string]any{"c-ip": "192.0.2.100", "sc-status": "200"}, entries[0].Data)
+	})
+
+	t.Run("Header without date", func(t *testing.T) {
+		parser, err := NewParser(LBTypeCloudFront, "", FieldOptions{})
+		require.NoError(t, err)
+
+		_, err = parseAll(t, parser, "#Fields: time c-ip\n21:02:31\t192.0.2.100\n")
+		require.Error(t, err)
+	})
+}
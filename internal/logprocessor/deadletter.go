@@ -0,0 +1,178 @@
+package logprocessor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Policies for records that cannot be parsed.
+const (
+	RecordErrorsSkip       = "skip"
+	RecordErrorsFail       = "fail"
+	RecordErrorsDeadLetter = "dead_letter"
+)
+
+// Sinks receiving rejected records with the dead_letter policy.
+const (
+	DeadLetterStdout = "stdout"
+	DeadLetterFile   = "file"
+	DeadLetterS3     = "s3"
+)
+
+// RecordErrors configures how records that cannot be parsed are handled:
+// skipped with a warning, failing the whole file, or skipped and sent to a
+// dead letter sink along with their location.
+type RecordErrors struct {
+	Policy string
+
+	// DeadLetter is the sink for the dead_letter policy. The file sink
+	// appends to DeadLetterFile; the s3 sink writes one object per log file
+	// under DeadLetterBucket and DeadLetterPrefix.
+	DeadLetter       string
+	DeadLetterFile   string
+	DeadLetterBucket string
+	DeadLetterPrefix string
+}
+
+// deadLetterSuffix ends the keys of objects written by the s3 sink. These are
+// never processed, so a sink writing into a bucket that notifies this
+// forwarder does not feed its own output back in.
+const deadLetterSuffix = ".rejected.jsonl"
+
+// maxDeadLetterSize caps the raw records of a log file held for the dead
+// letter sink, so a file that is not a log at all does not exhaust memory.
+const maxDeadLetterSize = 8 << 20 // 8MB
+
+// rejectedRecord is a record sent to a dead letter sink, as a JSON line.
+type rejectedRecord struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Error  string `json:"error"`
+	Raw    string `json:"raw"`
+}
+
+// deadLetterBuffer holds the records rejected from a log file until the file
+// has been parsed, up to maxDeadLetterSize.
+type deadLetterBuffer struct {
+	records []rejectedRecord
+	size    int
+	dropped int // records beyond maxDeadLetterSize
+}
+
+// add holds rec, or counts it as dropped if the buffer is full.
+func (b *deadLetterBuffer) add(rec rejectedRecord) {
+	if b.size+len(rec.Raw) > maxDeadLetterSize {
+		b.dropped++
+		return
+	}
+	b.records = append(b.records, rec)
+	b.size += len(rec.Raw)
+}
+
+// recordErrorsFromEnv reads the record error configuration from the environment.
+func recordErrorsFromEnv() (RecordErrors, error) {
+	re := RecordErrors{
+		Policy:           strings.ToLower(os.Getenv("RECORD_ERRORS")),
+		DeadLetter:       strings.ToLower(os.Getenv("DEAD_LETTER")),
+		DeadLetterFile:   os.Getenv("DEAD_LETTER_FILE"),
+		DeadLetterBucket: os.Getenv("DEAD_LETTER_BUCKET"),
+		DeadLetterPrefix: os.Getenv("DEAD_LETTER_PREFIX"),
+	}
+	if re.Policy == "" {
+		re.Policy = RecordErrorsSkip
+	}
+	return re, re.validate()
+}
+
+func (re RecordErrors) validate() error {
+	switch re.Policy {
+	case RecordErrorsSkip, RecordErrorsFail:
+		return nil
+	case RecordErrorsDeadLetter:
+	default:
+		return fmt.Errorf("invalid RECORD_ERRORS policy: %q (use 'skip', 'fail' or 'dead_letter')", re.Policy)
+	}
+
+	switch re.DeadLetter {
+	case DeadLetterStdout:
+		return nil
+	case DeadLetterFile:
+		if re.DeadLetterFile == "" {
+			return fmt.Errorf("DEAD_LETTER_FILE required")
+		}
+		return nil
+	case DeadLetterS3:
+		if re.DeadLetterBucket == "" {
+			return fmt.Errorf("DEAD_LETTER_BUCKET required")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid DEAD_LETTER sink: %q (use 'stdout', 'file' or 's3')", re.DeadLetter)
+	}
+}
+
+// deadLetterMu serializes appends to a dead letter file.
+var deadLetterMu sync.Mutex
+
+// sendDeadLetter writes the records rejected from the log file at source to
+// the configured sink. Records dropped from a full buffer are only counted.
+func (p *LogProcessor) sendDeadLetter(source string, rejected *deadLetterBuffer) error {
+	if rejected.dropped > 0 {
+		slog.Warn("dead letter buffer full, rejected records dropped", "source", source, "records", rejected.dropped)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, rec := range rejected.records {
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("encode rejected record: %w", err)
+		}
+	}
+
+	re := p.recordErrors
+	switch re.DeadLetter {
+	case DeadLetterStdout:
+		deadLetterMu.Lock()
+		defer deadLetterMu.Unlock()
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("write dead letter: %w", err)
+		}
+	case DeadLetterFile:
+		deadLetterMu.Lock()
+		defer deadLetterMu.Unlock()
+		f, err := os.OpenFile(re.DeadLetterFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open dead letter file: %w", err)
+		}
+		if _, err := f.Write(buf.Bytes()); err != nil {
+			f.Close()
+			return fmt.Errorf("write dead letter file: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("write dead letter file: %w", err)
+		}
+	case DeadLetterS3:
+		// One object per log file, so a retried file overwrites its records
+		key := re.DeadLetterPrefix + strings.TrimLeft(strings.TrimPrefix(source, "s3://"), "/") + deadLetterSuffix
+		_, err := p.s3.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(re.DeadLetterBucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(buf.Bytes()),
+		})
+		if err != nil {
+			return fmt.Errorf("put dead letter s3://%s/%s: %w", re.DeadLetterBucket, key, err)
+		}
+	}
+
+	slog.Warn("rejected records sent to dead letter", "source", source, "records", len(rejected.records), "sink", re.DeadLetter)
+	return nil
+}
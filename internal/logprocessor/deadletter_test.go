@@ -0,0 +1,152 @@
+package logprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// malformedCLBLog has a valid record, a record with an unterminated quote
+// and a record with an invalid timestamp, followed by another valid record.
+const malformedCLBLog = clbRecord + "\n" +
+	`2024-03-21T16:10:27Z my-clb 192.0.2.104:36217 10.0.0.24:80 0.1 0.1 0.1 200 200 0 29 "GET http://www.example.com:80/ HTTP/1.1` + "\n" +
+	`yesterday my-clb 192.0.2.104:36217 10.0.0.24:80 0.1 0.1 0.1 200 200 0 29 "GET / HTTP/1.1" "curl/7.38.0" - -` + "\n" +
+	clbRecord + "\n"
+
+func TestRecordErrorsFromEnv(t *testing.T) {
+	t.Run("Skip by default", func(t *testing.T) {
+		re, err := recordErrorsFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, RecordErrorsSkip, re.Policy)
+	})
+
+	t.Run("Dead letter file", func(t *testing.T) {
+		t.Setenv("RECORD_ERRORS", "dead_letter")
+		t.Setenv("DEAD_LETTER", "file")
+		t.Setenv("DEAD_LETTER_FILE", "/tmp/rejected.jsonl")
+		re, err := recordErrorsFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp/rejected.jsonl", re.DeadLetterFile)
+	})
+
+	t.Run("Dead letter requires a sink", func(t *testing.T) {
+		t.Setenv("RECORD_ERRORS", "dead_letter")
+		_, err := recordErrorsFromEnv()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "DEAD_LETTER")
+	})
+
+	t.Run("S3 sink requires a bucket", func(t *testing.T) {
+		t.Setenv("RECORD_ERRORS", "dead_letter")
+		t.Setenv("DEAD_LETTER", "s3")
+		_, err := recordErrorsFromEnv()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "DEAD_LETTER_BUCKET")
+	})
+
+	t.Run("Invalid policy", func(t *testing.T) {
+		t.Setenv("RECORD_ERRORS", "ignore")
+		_, err := recordErrorsFromEnv()
+		require.Error(t, err)
+	})
+}
+
+func TestDeadLetterBuffer(t *testing.T) {
+	var b deadLetterBuffer
+	raw := strings.Repeat("x", maxDeadLetterSize/4)
+	for line := 1; line <= 6; line++ {
+		b.add(rejectedRecord{Line: line, Raw: raw})
+	}
+	assert.Len(t, b.records, 4)
+	assert.Equal(t, 2, b.dropped)
+}
+
+func TestProcessLogsRecordErrors(t *testing.T) {
+	obj := types.S3ObjectInfo{Bucket: "logs", Key: "clb/2024/03/21/clb.log"}
+
+	setup := func(t *testing.T, re RecordErrors) (*LogProcessor, *MockS3API, *MockDestination) {
+		mockS3 := new(MockS3API)
+		mockS3.On("GetObject", keyIs(obj.Key)).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(strings.NewReader(malformedCLBLog)),
+		}, nil)
+
+		parser, err := NewParser(LBTypeCLB, "", FieldOptions{})
+		require.NoError(t, err)
+
+		mockDest := &MockDestination{}
+		lp := NewWithDeps(mockS3, parser, []destinations.Destination{mockDest})
+		lp.recordErrors = re
+		return lp, mockS3, mockDest
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		lp, _, mockDest := setup(t, RecordErrors{Policy: RecordErrorsSkip})
+
+		require.NoError(t, lp.ProcessLogs(context.Background(), obj))
+		assert.Len(t, mockDest.Entries(), 2)
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		lp, _, mockDest := setup(t, RecordErrors{Policy: RecordErrorsFail})
+
+		err := lp.ProcessLogs(context.Background(), obj)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "s3://logs/clb/2024/03/21/clb.log")
+		assert.Contains(t, err.Error(), "line 2")
+		assert.Len(t, mockDest.Entries(), 1)
+	})
+
+	t.Run("Dead letter file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rejected.jsonl")
+		lp, _, mockDest := setup(t, RecordErrors{Policy: RecordErrorsDeadLetter, DeadLetter: DeadLetterFile, DeadLetterFile: path})
+
+		require.NoError(t, lp.ProcessLogs(context.Background(), obj))
+		assert.Len(t, mockDest.Entries(), 2)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		require.Len(t, lines, 2)
+
+		var rec rejectedRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &rec))
+		assert.Equal(t, "s3://logs/clb/2024/03/21/clb.log", rec.Source)
+		assert.Equal(t, 3, rec.Line)
+		assert.Contains(t, rec.Error, "parse timestamp")
+		assert.True(t, strings.HasPrefix(rec.Raw, "yesterday my-clb"))
+	})
+
+	t.Run("Dead letter S3", func(t *testing.T) {
+		lp, mockS3, _ := setup(t, RecordErrors{Policy: RecordErrorsDeadLetter, DeadLetter: DeadLetterS3, DeadLetterBucket: "dlq", DeadLetterPrefix: "rejected/"})
+		mockS3.On("PutObject", mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+			body, _ := io.ReadAll(input.Body)
+			return *input.Bucket == "dlq" &&
+				*input.Key == "rejected/logs/clb/2024/03/21/clb.log.rejected.jsonl" &&
+				strings.Count(string(body), "\n") == 2
+		})).Return(&s3.PutObjectOutput{}, nil).Once()
+
+		require.NoError(t, lp.ProcessLogs(context.Background(), obj))
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Unreadable file fails", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(strings.NewReader("\x1f\x8bnot gzip")),
+		}, nil)
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{&MockDestination{}})
+		require.Error(t, lp.ProcessLogs(context.Background(), obj))
+	})
+}
@@ -0,0 +1,194 @@
+package logprocessor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// LBTypeAuto detects the log type of every object instead of using a fixed type.
+const LBTypeAuto LBType = "auto"
+
+// lbTypes are all supported log types.
+var lbTypes = []LBType{LBTypeALB, LBTypeNLB, LBTypeCLB, LBTypeALBConn}
+
+// albRequestTypes are the values of the type field of ALB access log entries.
+var albRequestTypes = map[string]bool{
+	"http":  true,
+	"https": true,
+	"h2":    true,
+	"grpcs": true,
+	"ws":    true,
+	"wss":   true,
+}
+
+// fieldFilters holds a FieldFilter per log type, for detection per object.
+type fieldFilters map[LBType]*FieldFilter
+
+// newFieldFilters creates the filters of all log types. The type fields of
+// opts (FIELDS_<TYPE>, e.g. FIELDS_NLB) select the fields of one type;
+// otherwise the fields in fieldConfig known to the type are used, which must
+// be at least one.
+func newFieldFilters(fieldConfig string, opts FieldOptions) (fieldFilters, error) {
+	names := splitFields(fieldConfig)
+	for _, name := range names {
+		if !slices.ContainsFunc(lbTypes, func(t LBType) bool {
+			fields, _ := selectableFields(t, opts)
+			return slices.Contains(fields, name)
+		}) {
+			return nil, fmt.Errorf("invalid field name: %q", name)
+		}
+	}
+
+	filters := make(fieldFilters, len(lbTypes))
+	for _, t := range lbTypes {
+		config := opts.TypeFields[t]
+		if config == "" && len(names) > 0 {
+			fields, _ := selectableFields(t, opts)
+			var known []string
+			for _, name := range names {
+				if slices.Contains(fields, name) {
+					known = append(known, name)
+				}
+			}
+			if len(known) == 0 {
+				return nil, fmt.Errorf("FIELDS has no %s fields, set %s", t, fieldsEnv(t))
+			}
+			config = strings.Join(known, ",")
+		}
+
+		f, err := NewFieldFilter(t, config, opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", fieldsEnv(t), err)
+		}
+		filters[t] = f
+	}
+	return filters, nil
+}
+
+// fieldsEnv returns the name of the variable selecting the fields of log type t.
+func fieldsEnv(t LBType) string {
+	return "FIELDS_" + strings.ToUpper(string(t))
+}
+
+func splitFields(fieldConfig string) []string {
+	if fieldConfig == "" {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(fieldConfig, ",") {
+		names = append(names, strings.TrimSpace(name))
+	}
+	return names
+}
+
+// detectKeyType returns the log type encoded in an ELB log file name:
+// connection logs are prefixed with conn_log., and the load balancer ID
+// starts with app. for ALB and net. for NLB, and has no prefix for CLB.
+func detectKeyType(key string) (LBType, bool) {
+	base := path.Base(key)
+	if strings.HasPrefix(base, "conn_log.") {
+		return LBTypeALBConn, true
+	}
+
+	m := keyComponentsRe.FindStringSubmatch(base)
+	if m == nil {
+		return "", false
+	}
+	switch lbID := m[3]; {
+	case strings.HasPrefix(lbID, "app."):
+		return LBTypeALB, true
+	case strings.HasPrefix(lbID, "net."):
+		return LBTypeNLB, true
+	case !strings.Contains(lbID, "."):
+		return LBTypeCLB, true
+	}
+	return "", false
+}
+
+// detectRecordType infers the log type from the shape of a record: ALB and
+// NLB entries start with a type (and NLB a version) before the time, CLB and
+// connection log entries start with the time, followed by the load balancer
+// name or the client IP respectively.
+func detectRecordType(record []string) (LBType, bool) {
+	isTime := func(i int) bool {
+		if i >= len(record) {
+			return false
+		}
+		_, err := time.Parse(time.RFC3339, record[i])
+		return err == nil
+	}
+
+	switch {
+	case len(record) > 1 && albRequestTypes[record[0]] && isTime(1):
+		return LBTypeALB, true
+	case len(record) > 2 && record[0] == "tls" && isTime(2):
+		return LBTypeNLB, true
+	case len(record) > 1 && isTime(0) && net.ParseIP(record[1]) != nil:
+		return LBTypeALBConn, true
+	case len(record) > 1 && isTime(0):
+		return LBTypeCLB, true
+	}
+	return "", false
+}
+
+// autoParser parses load balancer logs of any type, detected per object
+// from the file name or, failing that, from the first record.
+type autoParser struct {
+	filters fieldFilters
+}
+
+func newAutoParser(fieldConfig string, opts FieldOptions) (Parser, error) {
+	filters, err := newFieldFilters(fieldConfig, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &autoParser{filters: filters}, nil
+}
+
+// forKey returns the parser for the log file named key, or itself if the
+// type is to be detected from the first record.
+func (a *autoParser) forKey(key string) Parser {
+	if t, ok := detectKeyType(key); ok {
+		return &elbParser{fields: a.filters[t]}
+	}
+	return a
+}
+
+func (a *autoParser) Parse(r io.Reader, out chan<- types.LogEntry, reject RejectFunc) (ParseStats, error) {
+	br := bufio.NewReader(r)
+	first, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return ParseStats{}, fmt.Errorf("read record: %w", err)
+	}
+	if first == "" {
+		return ParseStats{}, nil
+	}
+
+	record, err := splitELBRecord(strings.TrimRight(first, "\n"))
+	if err != nil {
+		return ParseStats{}, fmt.Errorf("read record: %w", err)
+	}
+	t, ok := detectRecordType(record)
+	if !ok {
+		return ParseStats{}, fmt.Errorf("cannot detect log type from first record")
+	}
+
+	p := &elbParser{fields: a.filters[t]}
+	return p.Parse(io.MultiReader(strings.NewReader(first), br), out, reject)
+}
+
+// parserFor returns the parser for the log file named name.
+func (p *LogProcessor) parserFor(name string) Parser {
+	if a, ok := p.parser.(*autoParser); ok {
+		return a.forKey(name)
+	}
+	return p.parser
+}
@@ -0,0 +1,145 @@
+package logprocessor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	nlbRecord = `tls 2.0 2024-03-21T16:10:26Z net/my-nlb/c6e77e28c25b2234 g3d4b5e8bb8464cd 192.0.2.1:51341 10.0.0.1:443 5 2 98 246 - arn:aws:acm:us-east-2:123456789012:certificate/abcd - ECDHE-RSA-AES128-SHA tlsv12 - my-network-loadbalancer-c6e77e28c25b2234.elb.us-east-2.amazonaws.com - - - 2024-03-21T16:10:24`
+	clbRecord = `2024-03-21T16:10:26.071854Z my-clb 192.0.2.104:36217 10.0.0.24:80 0.000073 0.001048 0.000057 200 200 0 29 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.38.0" - -`
+)
+
+func TestDetectKeyType(t *testing.T) {
+	tests := []struct {
+		key  string
+		want LBType
+		ok   bool
+	}{
+		{regionPrefix + "2024/03/19/123456789012_elasticloadbalancing_eu-west-1_app.prod-api.1234567890abcdef_20240319T1405Z_10.0.0.1_abc123.log.gz", LBTypeALB, true},
+		{regionPrefix + "2024/03/19/123456789012_elasticloadbalancing_eu-west-1_net.prod-nlb.1234567890abcdef_20240319T1405Z_abc123.log.gz", LBTypeNLB, true},
+		{regionPrefix + "2024/03/19/123456789012_elasticloadbalancing_eu-west-1_prod-clb_20240319T1405Z_10.0.0.1_abc123.log", LBTypeCLB, true},
+		{regionPrefix + "2024/03/19/conn_log.123456789012_conn_log_eu-west-1_app.prod-api.1234567890abcdef_20240319T1405Z_10.0.0.1_abc123.log.gz", LBTypeALBConn, true},
+		{"exports/access.log.gz", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := detectKeyType(tt.key)
+		assert.Equal(t, tt.ok, ok, tt.key)
+		assert.Equal(t, tt.want, got, tt.key)
+	}
+}
+
+func TestDetectRecordType(t *testing.T) {
+	record := func(line string) []string {
+		return strings.Fields(line)
+	}
+
+	tests := []struct {
+		name   string
+		record []string
+		want   LBType
+		ok     bool
+	}{
+		{"ALB", record(`h2 2024-03-21T16:10:26.071854Z app/my-alb/50dc6c495c0c9188 192.0.2.104:36217`), LBTypeALB, true},
+		{"NLB", record(nlbRecord), LBTypeNLB, true},
+		{"CLB", record(clbRecord), LBTypeCLB, true},
+		{"Connection log", record(`2023-10-04T17:34:06.118354Z 203.0.113.1 57012 443 TLSv1.2`), LBTypeALBConn, true},
+		{"Unknown", record(`192.0.2.1 - - [21/Mar/2024:16:10:26 +0000] "GET / HTTP/1.1" 200`), "", false},
+		{"Empty", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := detectRecordType(tt.record)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewFieldFilters(t *testing.T) {
+	t.Run("All fields by default", func(t *testing.T) {
+		filters, err := newFieldFilters("", FieldOptions{})
+		require.NoError(t, err)
+		require.Len(t, filters, len(lbTypes))
+		for _, lbType := range lbTypes {
+			assert.Equal(t, lbType, filters[lbType].LBType())
+			assert.True(t, filters[lbType].Includes(1))
+		}
+	})
+
+	t.Run("FIELDS applies to the types that know the field", func(t *testing.T) {
+		filters, err := newFieldFilters("time, elb, client_ip", FieldOptions{TypeFields: map[LBType]string{LBTypeALBConn: "time,conn_trace_id"}})
+		require.NoError(t, err)
+
+		assert.True(t, filters[LBTypeALB].Includes(fieldIndex(albFields, "elb")))
+		assert.False(t, filters[LBTypeALB].Includes(fieldIndex(albFields, "type")))
+		assert.True(t, filters[LBTypeNLB].Includes(fieldIndex(nlbFields, "client_ip")))
+		assert.True(t, filters[LBTypeCLB].Includes(fieldIndex(clbFields, "elb")))
+		assert.True(t, filters[LBTypeALBConn].Includes(fieldIndex(albConnFields, "conn_trace_id")))
+		assert.False(t, filters[LBTypeALBConn].Includes(fieldIndex(albConnFields, "client_ip")))
+	})
+
+	t.Run("Type without any of the fields", func(t *testing.T) {
+		_, err := newFieldFilters("elb", FieldOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "FIELDS_ALB_CONN")
+	})
+
+	t.Run("Unknown field", func(t *testing.T) {
+		_, err := newFieldFilters("time,nonexistent", FieldOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid field name")
+	})
+
+	t.Run("Invalid override", func(t *testing.T) {
+		_, err := newFieldFilters("", FieldOptions{TypeFields: map[LBType]string{LBTypeNLB: "request"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "FIELDS_NLB")
+	})
+}
+
+func TestHandleInputDetect(t *testing.T) {
+	dir := t.TempDir()
+	alb, err := os.ReadFile("testdata/sample.log")
+	require.NoError(t, err)
+
+	// Types of ELB-named files come from the key, others from the first record
+	nlbName := "123456789012_elasticloadbalancing_eu-west-1_net.my-nlb.c6e77e28c25b2234_20240321T1610Z_abc123.log.gz"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, nlbName), gzipData(t, []byte(nlbRecord+"\n")).Bytes(), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "clb.log"), []byte(clbRecord+"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "alb.log"), alb, 0o644))
+
+	filters, err := newFieldFilters("time,elb", FieldOptions{})
+	require.NoError(t, err)
+
+	mockDest := &MockDestination{}
+	lp := NewWithDeps(nil, &autoParser{filters: filters}, []destinations.Destination{mockDest})
+
+	require.NoError(t, lp.HandleInput(context.Background(), dir, BackfillOptions{}))
+
+	elbs := make(map[string]int)
+	for _, entry := range mockDest.Entries() {
+		assert.Len(t, entry.Data, 2)
+		elbs[entry.Data["elb"].(string)]++
+	}
+	assert.Equal(t, 1, elbs["net/my-nlb/c6e77e28c25b2234"])
+	assert.Equal(t, 1, elbs["my-clb"])
+	assert.Len(t, mockDest.Entries(), 7)
+}
+
+func TestAutoParserUndetectable(t *testing.T) {
+	parser, err := NewParser(LBTypeAuto, "", FieldOptions{})
+	require.NoError(t, err)
+
+	_, err = parser.Parse(strings.NewReader("not a log line\n"), make(chan<- types.LogEntry, 1), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot detect log type")
+}
@@ -0,0 +1,200 @@
+package logprocessor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// elbParser parses the space-separated records of load balancer access logs
+// and ALB connection logs.
+type elbParser struct {
+	fields *FieldFilter
+}
+
+// elbParserFactory returns the ParserFactory of a load balancer log type.
+func elbParserFactory(lbType LBType) ParserFactory {
+	return func(fieldConfig string, opts FieldOptions) (Parser, error) {
+		fields, err := NewFieldFilter(lbType, fieldConfig, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &elbParser{fields: fields}, nil
+	}
+}
+
+func (p *elbParser) Parse(r io.Reader, out chan<- types.LogEntry, reject RejectFunc) (ParseStats, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+
+	stats := ParseStats{Type: p.fields.LBType()}
+	firstRecord := true
+	var tokenizer elbTokenizer
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		// The only copy of the line: fields are substrings of it
+		raw := scanner.Text()
+
+		record, err := tokenizer.split(raw)
+		if err == nil && firstRecord {
+			slog.Debug("log format", "type", p.fields.LBType(), "fields", len(record), "expected", p.fields.TotalFields())
+			firstRecord = false
+		}
+
+		var entry types.LogEntry
+		if err == nil {
+			entry, err = p.recordToEntry(record)
+		}
+		if err != nil {
+			if err := stats.reject(reject, line, raw, err); err != nil {
+				return stats, err
+			}
+			continue
+		}
+		p.fields.expand(entry.Data)
+		stats.count(len(record), p.fields.TotalFields())
+		out <- entry
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("read record: %w", err)
+	}
+	return stats, nil
+}
+
+// recordToEntry creates an entry from the included fields of a record. Their
+// values share the memory of the line, except for fields with escaped quotes.
+func (p *elbParser) recordToEntry(record []elbField) (types.LogEntry, error) {
+	// Time field is at index 1 for ALB, index 2 for NLB, index 0 for CLB and ALB connection logs
+	timeIdx := 1
+	switch p.fields.LBType() {
+	case LBTypeNLB:
+		timeIdx = 2
+	case LBTypeCLB, LBTypeALBConn:
+		timeIdx = 0
+	}
+
+	// Only require enough fields to extract the timestamp
+	if len(record) <= timeIdx {
+		return types.LogEntry{}, fmt.Errorf("record too short: need at least %d fields for timestamp, got %d", timeIdx+1, len(record))
+	}
+
+	ts, err := p.eventTime(record, timeIdx)
+	if err != nil {
+		return types.LogEntry{}, fmt.Errorf("parse timestamp: %w", err)
+	}
+
+	// Process whatever fields exist, skip missing ones
+	data := make(map[string]any, min(len(record), len(p.fields.included)))
+	for i, val := range record {
+		if name, ok := p.fields.field(i); ok {
+			data[name] = p.fields.value(name, val.String())
+		}
+	}
+
+	return types.LogEntry{Data: data, Timestamp: ts}, nil
+}
+
+// eventTime returns the timestamp of a record from the field chosen with
+// TIME_FIELD_<TYPE>, falling back to the time at timeIdx if that field is
+// missing or -.
+func (p *elbParser) eventTime(record []elbField, timeIdx int) (time.Time, error) {
+	if i := p.fields.timeIdx; i >= 0 && i < len(record) && record[i].val != "-" {
+		return parseFieldTime(record[i].val)
+	}
+	return time.Parse(time.RFC3339, record[timeIdx].val)
+}
+
+// elbTokenizer splits log lines into their space-separated fields. Fields
+// may be quoted, with "" for a literal quote. The fields of a record are
+// substrings of its line and the slice holding them is reused for the next
+// one, so splitting a line does not allocate.
+type elbTokenizer struct {
+	fields []elbField
+}
+
+// elbField is a field of a record, without its quotes.
+type elbField struct {
+	val     string
+	escaped bool // whether val contains "" for a literal quote
+}
+
+// String returns the field value, which is only copied to unescape quotes.
+func (f elbField) String() string {
+	if f.escaped {
+		return strings.ReplaceAll(f.val, `""`, `"`)
+	}
+	return f.val
+}
+
+// split returns the fields of line. The returned slice is valid until the
+// next call.
+func (t *elbTokenizer) split(line string) ([]elbField, error) {
+	fields := t.fields[:0]
+	defer func() { t.fields = fields[:0] }()
+
+	for {
+		if len(line) == 0 || line[0] != '"' {
+			i := strings.IndexByte(line, ' ')
+			if i < 0 {
+				fields = append(fields, elbField{val: line})
+				return fields, nil
+			}
+			fields = append(fields, elbField{val: line[:i]})
+			line = line[i+1:]
+			continue
+		}
+
+		// Find the closing quote, skipping escaped quotes
+		end, escaped := -1, false
+		for i := 1; i < len(line); {
+			j := strings.IndexByte(line[i:], '"')
+			if j < 0 {
+				break
+			}
+			i += j
+			if i+1 < len(line) && line[i+1] == '"' {
+				escaped = true
+				i += 2
+				continue
+			}
+			end = i
+			break
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated quoted field %d", len(fields)+1)
+		}
+		fields = append(fields, elbField{val: line[1:end], escaped: escaped})
+
+		line = line[end+1:]
+		if len(line) == 0 {
+			return fields, nil
+		}
+		if line[0] != ' ' {
+			return nil, fmt.Errorf("unexpected %q after quoted field %d", line[0], len(fields))
+		}
+		line = line[1:]
+	}
+}
+
+// splitELBRecord splits a log line into its space-separated fields, see
+// elbTokenizer.
+func splitELBRecord(line string) ([]string, error) {
+	var t elbTokenizer
+	fields, err := t.split(line)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]string, len(fields))
+	for i, f := range fields {
+		record[i] = f.String()
+	}
+	return record, nil
+}
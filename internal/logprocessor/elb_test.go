@@ -0,0 +1,252 @@
+package logprocessor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitELBRecord(t *testing.T) {
+	t.Run("Quoted fields", func(t *testing.T) {
+		record, err := splitELBRecord(`https 2024-03-21T16:10:26Z "GET / HTTP/1.1" "curl ""quoted""" - ""`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https", "2024-03-21T16:10:26Z", "GET / HTTP/1.1", `curl "quoted"`, "-", ""}, record)
+	})
+
+	t.Run("Unterminated quote", func(t *testing.T) {
+		_, err := splitELBRecord(`https 2024-03-21T16:10:26Z "GET / HTTP/1.1`)
+		require.Error(t, err)
+	})
+
+	t.Run("Text after closing quote", func(t *testing.T) {
+		_, err := splitELBRecord(`https "GET"x -`)
+		require.Error(t, err)
+	})
+}
+
+func TestELBTokenizer(t *testing.T) {
+	t.Run("Fields point into the line", func(t *testing.T) {
+		var tokenizer elbTokenizer
+		line := `https "GET / HTTP/1.1" "curl ""quoted"""`
+		fields, err := tokenizer.split(line)
+		require.NoError(t, err)
+		require.Len(t, fields, 3)
+		assert.Equal(t, "GET / HTTP/1.1", fields[1].String())
+		assert.False(t, fields[1].escaped)
+		assert.Equal(t, `curl "quoted"`, fields[2].String())
+		assert.True(t, fields[2].escaped)
+	})
+
+	t.Run("Splitting does not allocate", func(t *testing.T) {
+		var tokenizer elbTokenizer
+		line := albRequestRecord
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := tokenizer.split(line); err != nil {
+				t.Fatal(err)
+			}
+		})
+		assert.Zero(t, allocs)
+	})
+
+	t.Run("Cost grows with the included fields", func(t *testing.T) {
+		all, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
+		require.NoError(t, err)
+		selected, err := NewFieldFilter(LBTypeALB, "time,elb", FieldOptions{})
+		require.NoError(t, err)
+
+		var tokenizer elbTokenizer
+		record, err := tokenizer.split(albRequestRecord)
+		require.NoError(t, err)
+
+		allocs := func(fields *FieldFilter) float64 {
+			p := &elbParser{fields: fields}
+			return testing.AllocsPerRun(100, func() {
+				if _, err := p.recordToEntry(record); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+		assert.Less(t, allocs(selected), allocs(all)/4)
+	})
+}
+
+// elbFields returns a tokenized record with the given field values.
+func elbFields(record []string) []elbField {
+	fields := make([]elbField, len(record))
+	for i, val := range record {
+		fields[i] = elbField{val: val}
+	}
+	return fields
+}
+
+// parseStrings parses ALB records the way elbParser did before elbTokenizer,
+// with encoding/csv, as a baseline for benchmarks.
+func parseStrings(p *elbParser, r io.Reader, out chan<- types.LogEntry) error {
+	cr := csv.NewReader(r)
+	cr.Comma = ' '
+	cr.FieldsPerRecord = -1
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ts, err := time.Parse(time.RFC3339, record[1])
+		if err != nil {
+			return err
+		}
+		data := make(map[string]any)
+		for i, val := range record {
+			if name, ok := p.fields.field(i); ok {
+				data[name] = p.fields.value(name, val)
+			}
+		}
+		out <- types.LogEntry{Data: data, Timestamp: ts}
+	}
+}
+
+// benchCorpus returns a function opening the ALB log corpus for benchmarks,
+// and its decompressed size. ELB_BENCH_CORPUS names a (gzipped) log file to
+// use, such as a multi-GB concatenation of real logs; the default is the
+// sample log repeated to a few MB.
+func benchCorpus(b *testing.B) (func() io.ReadCloser, int64) {
+	if path := os.Getenv("ELB_BENCH_CORPUS"); path != "" {
+		open := func() io.ReadCloser {
+			f, err := os.Open(path)
+			require.NoError(b, err)
+			r, err := maybeGunzip(f)
+			require.NoError(b, err)
+			return manifestReader{r, f}
+		}
+
+		// Throughput is reported for the parsed log, so count the bytes
+		// after decompression
+		r := open()
+		defer r.Close()
+		size, err := io.Copy(io.Discard, r)
+		require.NoError(b, err)
+		return open, size
+	}
+
+	sample, err := os.ReadFile("testdata/sample.log")
+	require.NoError(b, err)
+	corpus := bytes.Repeat(sample, (4<<20)/len(sample))
+	return func() io.ReadCloser {
+		return io.NopCloser(bytes.NewReader(corpus))
+	}, int64(len(corpus))
+}
+
+func BenchmarkParseALB(b *testing.B) {
+	open, size := benchCorpus(b)
+
+	parsers := []struct {
+		name  string
+		parse func(p *elbParser, r io.Reader, out chan<- types.LogEntry) error
+	}{
+		{"Tokenizer", func(p *elbParser, r io.Reader, out chan<- types.LogEntry) error {
+			_, err := p.Parse(r, out, nil)
+			return err
+		}},
+		{"Strings", parseStrings},
+	}
+	for _, fieldConfig := range []string{"", "time,elb,elb_status_code,target_processing_time"} {
+		fields, err := NewFieldFilter(LBTypeALB, fieldConfig, FieldOptions{})
+		require.NoError(b, err)
+		p := &elbParser{fields: fields}
+
+		name := "AllFields"
+		if fieldConfig != "" {
+			name = "SelectedFields"
+		}
+		for _, parser := range parsers {
+			b.Run(name+"/"+parser.name, func(b *testing.B) {
+				b.SetBytes(size)
+				b.ReportAllocs()
+				for range b.N {
+					out := make(chan types.LogEntry, 1000)
+					done := make(chan struct{})
+					go func() {
+						for range out {
+						}
+						close(done)
+					}()
+
+					r := open()
+					err := parser.parse(p, r, out)
+					close(out)
+					<-done
+					r.Close()
+					require.NoError(b, err)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkELBTokenizer(b *testing.B) {
+	line := albRequestRecord
+	var tokenizer elbTokenizer
+	b.SetBytes(int64(len(line)))
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := tokenizer.split(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEventTimeField(t *testing.T) {
+	t.Run("Chosen field", func(t *testing.T) {
+		parser, err := NewParser(LBTypeALB, "", FieldOptions{TimeFields: map[LBType]string{LBTypeALB: "request_creation_time"}})
+		require.NoError(t, err)
+
+		entries, err := parseAll(t, parser, albRequestRecord+"\n")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, time.Date(2024, 3, 21, 16, 10, 26, 70000000, time.UTC), entries[0].Timestamp)
+	})
+
+	t.Run("Falls back to time if missing", func(t *testing.T) {
+		parser, err := NewParser(LBTypeALB, "", FieldOptions{TimeFields: map[LBType]string{LBTypeALB: "request_creation_time"}})
+		require.NoError(t, err)
+
+		record := strings.Replace(albRequestRecord, "2024-03-21T16:10:26.070000Z", "-", 1)
+		entries, err := parseAll(t, parser, record+"\n")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, time.Date(2024, 3, 21, 16, 10, 26, 71854000, time.UTC), entries[0].Timestamp)
+	})
+
+	t.Run("Time without zone", func(t *testing.T) {
+		parser, err := NewParser(LBTypeNLB, "", FieldOptions{TimeFields: map[LBType]string{LBTypeNLB: "tls_connection_creation_time"}})
+		require.NoError(t, err)
+
+		// The sample record lacks the last two fields
+		entries, err := parseAll(t, parser, nlbRecord+" - 2024-03-21T16:10:25\n")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, time.Date(2024, 3, 21, 16, 10, 25, 0, time.UTC), entries[0].Timestamp)
+	})
+
+	t.Run("Not a time field", func(t *testing.T) {
+		_, err := NewParser(LBTypeALB, "", FieldOptions{TimeFields: map[LBType]string{LBTypeALB: "elb"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TIME_FIELD_ALB")
+	})
+
+	t.Run("Log type without time fields", func(t *testing.T) {
+		_, err := NewParser(LBTypeCloudFront, "", FieldOptions{TimeFields: map[LBType]string{LBTypeCloudFront: "time"}})
+		require.Error(t, err)
+	})
+}
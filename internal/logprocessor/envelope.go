@@ -0,0 +1,142 @@
+package logprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"golang.org/x/sync/errgroup"
+)
+
+// s3TestEvent is the event name of the test message S3 sends when a
+// notification configuration is created.
+const s3TestEvent = "s3:TestEvent"
+
+// eventBridgeS3Detail is the detail of an EventBridge S3 object event.
+type eventBridgeS3Detail struct {
+	Bucket struct {
+		Name string `json:"name"`
+	} `json:"bucket"`
+	Object struct {
+		Key string `json:"key"`
+	} `json:"object"`
+}
+
+// HandleSNSEvent processes S3 notifications fanned out through an SNS topic.
+func (p *LogProcessor) HandleSNSEvent(ctx context.Context, event events.SNSEvent) error {
+	var objs []types.S3ObjectInfo
+	for _, r := range event.Records {
+		o, err := notificationObjects([]byte(r.SNS.Message))
+		if err != nil {
+			return fmt.Errorf("SNS message %s: %w", r.SNS.MessageID, err)
+		}
+		objs = append(objs, o...)
+	}
+	return p.processObjects(ctx, objs)
+}
+
+// HandleEventBridgeEvent processes an EventBridge S3 "Object Created" event.
+func (p *LogProcessor) HandleEventBridgeEvent(ctx context.Context, event events.EventBridgeEvent) error {
+	objs, err := eventBridgeObjects(event)
+	if err != nil {
+		return err
+	}
+	return p.processObjects(ctx, objs)
+}
+
+func (p *LogProcessor) processObjects(ctx context.Context, objs []types.S3ObjectInfo) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrency)
+
+	for _, obj := range objs {
+		g.Go(func() error {
+			return p.processObject(ctx, obj)
+		})
+	}
+
+	return g.Wait()
+}
+
+// notificationObjects extracts the objects referenced by a notification payload.
+// The payload is an S3 event notification, optionally wrapped in an SNS
+// notification, or an EventBridge S3 event.
+func notificationObjects(payload []byte) ([]types.S3ObjectInfo, error) {
+	var probe struct {
+		Type       string `json:"Type"`
+		Message    string `json:"Message"`
+		DetailType string `json:"detail-type"`
+		Event      string `json:"Event"`
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return nil, fmt.Errorf("decode notification: %w", err)
+	}
+
+	switch {
+	case probe.Event == s3TestEvent:
+		slog.Info("ignoring S3 test event")
+		return nil, nil
+	case probe.Type == "Notification":
+		// SNS envelope without raw message delivery
+		return notificationObjects([]byte(probe.Message))
+	case probe.DetailType != "":
+		var event events.EventBridgeEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("decode EventBridge event: %w", err)
+		}
+		return eventBridgeObjects(event)
+	default:
+		var event events.S3Event
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("decode S3 event: %w", err)
+		}
+		return s3EventObjects(event)
+	}
+}
+
+// eventBridgeObjects extracts the object referenced by an EventBridge S3 event.
+func eventBridgeObjects(event events.EventBridgeEvent) ([]types.S3ObjectInfo, error) {
+	if event.DetailType != "Object Created" {
+		slog.Info("ignoring EventBridge event", "detail_type", event.DetailType)
+		return nil, nil
+	}
+
+	var detail eventBridgeS3Detail
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		return nil, fmt.Errorf("decode EventBridge detail: %w", err)
+	}
+	if detail.Bucket.Name == "" || detail.Object.Key == "" {
+		return nil, fmt.Errorf("EventBridge event %s has no S3 object", event.ID)
+	}
+	return []types.S3ObjectInfo{{Bucket: detail.Bucket.Name, Key: detail.Object.Key}}, nil
+}
+
+// s3EventObjects extracts the objects created according to an S3 event
+// notification. Object keys are URL-decoded and records for other event
+// types (removals, restores, ...) are skipped. Event names may carry the
+// "s3:" prefix used by MinIO.
+func s3EventObjects(event events.S3Event) ([]types.S3ObjectInfo, error) {
+	objs := make([]types.S3ObjectInfo, 0, len(event.Records))
+	for _, r := range event.Records {
+		name := strings.TrimPrefix(r.EventName, "s3:")
+		if name != "" && !strings.HasPrefix(name, "ObjectCreated:") {
+			slog.Info("ignoring S3 event", "event", r.EventName, "key", r.S3.Object.Key)
+			continue
+		}
+
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("decode object key %q: %w", r.S3.Object.Key, err)
+		}
+
+		objs = append(objs, types.S3ObjectInfo{
+			Bucket: r.S3.Bucket.Name,
+			Key:    key,
+		})
+	}
+	return objs, nil
+}
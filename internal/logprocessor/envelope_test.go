@@ -0,0 +1,165 @@
+package logprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snsNotificationBody wraps a message in an SNS notification envelope.
+func snsNotificationBody(t *testing.T, message string) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"Type":      "Notification",
+		"MessageId": "sns-1",
+		"TopicArn":  "arn:aws:sns:eu-west-1:123456789012:alb-logs",
+		"Message":   message,
+	})
+	require.NoError(t, err)
+	return string(data)
+}
+
+const eventBridgeBody = `{
+	"version": "0",
+	"id": "eb-1",
+	"detail-type": "Object Created",
+	"source": "aws.s3",
+	"detail": {
+		"version": "0",
+		"bucket": {"name": "test-bucket"},
+		"object": {"key": "logs/file1.log.gz", "size": 1024},
+		"reason": "PutObject"
+	}
+}`
+
+func TestNotificationObjects(t *testing.T) {
+	want := []types.S3ObjectInfo{{Bucket: "test-bucket", Key: "logs/file1.log.gz"}}
+
+	t.Run("S3 event", func(t *testing.T) {
+		objs, err := notificationObjects([]byte(s3NotificationBody("test-bucket", "logs/file1.log.gz")))
+		require.NoError(t, err)
+		assert.Equal(t, want, objs)
+	})
+
+	t.Run("SNS wrapped S3 event", func(t *testing.T) {
+		body := snsNotificationBody(t, s3NotificationBody("test-bucket", "logs/file1.log.gz"))
+		objs, err := notificationObjects([]byte(body))
+		require.NoError(t, err)
+		assert.Equal(t, want, objs)
+	})
+
+	t.Run("EventBridge event", func(t *testing.T) {
+		objs, err := notificationObjects([]byte(eventBridgeBody))
+		require.NoError(t, err)
+		assert.Equal(t, want, objs)
+	})
+
+	t.Run("URL-encoded key is decoded", func(t *testing.T) {
+		objs, err := notificationObjects([]byte(s3NotificationBody("test-bucket", "logs/my+file%3D1.log.gz")))
+		require.NoError(t, err)
+		require.Len(t, objs, 1)
+		assert.Equal(t, "logs/my file=1.log.gz", objs[0].Key)
+	})
+
+	t.Run("S3 test event is ignored", func(t *testing.T) {
+		body := `{"Service":"Amazon S3","Event":"s3:TestEvent","Time":"2024-03-21T10:15:30.000Z","Bucket":"test-bucket"}`
+		objs, err := notificationObjects([]byte(body))
+		require.NoError(t, err)
+		assert.Empty(t, objs)
+	})
+
+	t.Run("Non-create events are ignored", func(t *testing.T) {
+		body := `{"Records":[{"eventSource":"aws:s3","eventName":"ObjectRemoved:Delete","s3":{"bucket":{"name":"test-bucket"},"object":{"key":"logs/file1.log.gz"}}}]}`
+		objs, err := notificationObjects([]byte(body))
+		require.NoError(t, err)
+		assert.Empty(t, objs)
+
+		body = strings.Replace(eventBridgeBody, "Object Created", "Object Deleted", 1)
+		objs, err = notificationObjects([]byte(body))
+		require.NoError(t, err)
+		assert.Empty(t, objs)
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		_, err := notificationObjects([]byte("not json"))
+		require.Error(t, err)
+	})
+
+	t.Run("EventBridge event without object", func(t *testing.T) {
+		_, err := notificationObjects([]byte(`{"id":"eb-2","detail-type":"Object Created","detail":{}}`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no S3 object")
+	})
+}
+
+func TestHandleSNSEvent(t *testing.T) {
+	mockS3 := new(MockS3API)
+	mockDest := &MockDestination{}
+
+	mockS3.On("GetObject", keyIs("logs/file1.log.gz")).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(loadTestData(t)),
+	}, nil).Once()
+
+	lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+
+	event := events.SNSEvent{
+		Records: []events.SNSEventRecord{
+			{SNS: events.SNSEntity{MessageID: "sns-1", Message: s3NotificationBody("test-bucket", "logs/file1.log.gz")}},
+		},
+	}
+
+	err := lp.HandleSNSEvent(context.Background(), event)
+	require.NoError(t, err)
+	assert.Len(t, mockDest.Entries(), 5)
+	mockS3.AssertExpectations(t)
+}
+
+func TestHandleEventBridgeEvent(t *testing.T) {
+	mockS3 := new(MockS3API)
+	mockDest := &MockDestination{}
+
+	mockS3.On("GetObject", keyIs("logs/file1.log.gz")).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(loadTestData(t)),
+	}, nil).Once()
+
+	lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+
+	var event events.EventBridgeEvent
+	require.NoError(t, json.Unmarshal([]byte(eventBridgeBody), &event))
+
+	err := lp.HandleEventBridgeEvent(context.Background(), event)
+	require.NoError(t, err)
+	assert.Len(t, mockDest.Entries(), 5)
+	mockS3.AssertExpectations(t)
+}
+
+func TestHandleSQSEventWithSNSEnvelope(t *testing.T) {
+	mockS3 := new(MockS3API)
+	mockDest := &MockDestination{}
+
+	mockS3.On("GetObject", keyIs("logs/file1.log.gz")).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(loadTestData(t)),
+	}, nil).Once()
+
+	lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{MessageId: "msg-1", Body: snsNotificationBody(t, s3NotificationBody("test-bucket", "logs/file1.log.gz"))},
+		},
+	}
+
+	resp, err := lp.HandleSQSEvent(context.Background(), event)
+	require.NoError(t, err)
+	assert.Empty(t, resp.BatchItemFailures)
+	assert.Len(t, mockDest.Entries(), 5)
+}
@@ -0,0 +1,100 @@
+package logprocessor
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// expandedFields are the subfields that composite load balancer fields are
+// split into when FieldOptions.Expanded is set (EXPAND_FIELDS). An address field named
+// <name>:port is split into <name>_ip and <name>_port, and the request line
+// into its method, URL parts and protocol version.
+var expandedFields = map[string][]string{
+	"client:port":  {"client_ip", "client_port"},
+	"target:port":  {"target_ip", "target_port"},
+	"backend:port": {"backend_ip", "backend_port"},
+	"request":      {"http_method", "url", "url_scheme", "url_host", "url_port", "url_path", "url_query", "http_version"},
+}
+
+// selectableFields returns the names FIELDS can select for the given log
+// type: its fields and, if composite fields are expanded, their subfields.
+func selectableFields(lbType LBType, opts FieldOptions) ([]string, error) {
+	fields, err := typeFields(lbType, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Expanded {
+		return fields, nil
+	}
+
+	names := slices.Clone(fields)
+	for _, name := range fields {
+		names = append(names, expandedFields[name]...)
+	}
+	return names, nil
+}
+
+// expand adds the included subfields of the composite fields in data, and
+// removes the composite fields that are only there to be expanded. Missing
+// and malformed values have no subfields.
+func (f *FieldFilter) expand(data map[string]any) {
+	if !f.expanded {
+		return
+	}
+	for name := range expandedFields {
+		v, ok := data[name]
+		if !ok {
+			continue
+		}
+		if raw, ok := v.(string); ok {
+			for sub, val := range splitCompositeField(name, raw) {
+				if f.included[sub] {
+					data[sub] = f.value(sub, val)
+				}
+			}
+		}
+		if !f.included[name] {
+			delete(data, name)
+		}
+	}
+}
+
+// splitCompositeField returns the non-empty subfields of a composite field.
+func splitCompositeField(name, raw string) map[string]string {
+	sub := make(map[string]string)
+	if prefix, ok := strings.CutSuffix(name, ":port"); ok {
+		// IPv6 addresses contain colons too, so the port follows the last one
+		i := strings.LastIndexByte(raw, ':')
+		if i < 0 {
+			return nil
+		}
+		sub[prefix+"_ip"] = strings.Trim(raw[:i], "[]")
+		sub[prefix+"_port"] = raw[i+1:]
+		return sub
+	}
+
+	// The request line is "METHOD URL PROTOCOL", with - for parts that
+	// could not be parsed
+	parts := strings.SplitN(raw, " ", 3)
+	if len(parts) != 3 {
+		return nil
+	}
+	setPart := func(key, val string) {
+		if val != "" && val != "-" {
+			sub[key] = val
+		}
+	}
+	setPart("http_method", parts[0])
+	setPart("url", parts[1])
+	setPart("http_version", strings.TrimSpace(parts[2]))
+
+	if u, err := url.Parse(parts[1]); err == nil && parts[1] != "-" {
+		setPart("url_scheme", u.Scheme)
+		setPart("url_host", u.Hostname())
+		setPart("url_port", u.Port())
+		setPart("url_path", u.EscapedPath())
+		setPart("url_query", u.RawQuery)
+	}
+	return sub
+}
@@ -0,0 +1,118 @@
+package logprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const albRequestRecord = `https 2024-03-21T16:10:26.071854Z app/my-alb/50dc6c495c0c9188 192.0.2.104:36217 10.0.0.24:8080 0.000 0.001 0.000 200 200 34 366 "GET https://www.example.com:443/api/users?page=2 HTTP/1.1" "curl/7.46.0" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337262-36d228ad5d99923122bbe354" "-" "-" 0 2024-03-21T16:10:26.070000Z "forward" "-" "-" "10.0.0.24:8080" "200" "-" "-"`
+
+func TestSplitCompositeField(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		raw   string
+		want  map[string]string
+	}{
+		{"Client address", "client:port", "192.0.2.104:36217", map[string]string{"client_ip": "192.0.2.104", "client_port": "36217"}},
+		{"IPv6 address", "target:port", "2001:db8::1:8080", map[string]string{"target_ip": "2001:db8::1", "target_port": "8080"}},
+		{"Bracketed IPv6 address", "backend:port", "[2001:db8::1]:80", map[string]string{"backend_ip": "2001:db8::1", "backend_port": "80"}},
+		{"No port", "client:port", "192.0.2.104", nil},
+		{"Request", "request", "GET https://www.example.com:443/api/users?page=2 HTTP/1.1", map[string]string{
+			"http_method":  "GET",
+			"url":          "https://www.example.com:443/api/users?page=2",
+			"url_scheme":   "https",
+			"url_host":     "www.example.com",
+			"url_port":     "443",
+			"url_path":     "/api/users",
+			"url_query":    "page=2",
+			"http_version": "HTTP/1.1",
+		}},
+		{"Unparsed request", "request", "- - - ", map[string]string{}},
+		{"Not a request", "request", "garbage", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitCompositeField(tt.field, tt.raw)
+			if tt.want == nil {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpandFields(t *testing.T) {
+	t.Run("Disabled by default", func(t *testing.T) {
+		parser, err := NewParser(LBTypeALB, "", FieldOptions{})
+		require.NoError(t, err)
+
+		entries, err := parseAll(t, parser, albRequestRecord+"\n")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.NotContains(t, entries[0].Data, "client_ip")
+		assert.Equal(t, "192.0.2.104:36217", entries[0].Data["client:port"])
+	})
+
+	t.Run("All fields", func(t *testing.T) {
+		parser, err := NewParser(LBTypeALB, "", FieldOptions{Expanded: true})
+		require.NoError(t, err)
+
+		entries, err := parseAll(t, parser, albRequestRecord+"\n")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		data := entries[0].Data
+		assert.Equal(t, "192.0.2.104:36217", data["client:port"])
+		assert.Equal(t, "192.0.2.104", data["client_ip"])
+		assert.Equal(t, "36217", data["client_port"])
+		assert.Equal(t, "10.0.0.24", data["target_ip"])
+		assert.Equal(t, "8080", data["target_port"])
+		assert.Equal(t, "GET", data["http_method"])
+		assert.Equal(t, "www.example.com", data["url_host"])
+		assert.Equal(t, "443", data["url_port"])
+		assert.Equal(t, "/api/users", data["url_path"])
+		assert.Equal(t, "page=2", data["url_query"])
+		assert.Equal(t, "HTTP/1.1", data["http_version"])
+	})
+
+	t.Run("FIELDS selects subfields", func(t *testing.T) {
+		parser, err := NewParser(LBTypeALB, "time,client_ip,http_method,url_path", FieldOptions{Expanded: true})
+		require.NoError(t, err)
+
+		entries, err := parseAll(t, parser, albRequestRecord+"\n")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		data := entries[0].Data
+		assert.Len(t, data, 4)
+		assert.Equal(t, "192.0.2.104", data["client_ip"])
+		assert.Equal(t, "GET", data["http_method"])
+		assert.Equal(t, "/api/users", data["url_path"])
+	})
+
+	t.Run("Subfields of missing values", func(t *testing.T) {
+		parser, err := NewParser(LBTypeCLB, "backend_ip,elb", FieldOptions{Expanded: true})
+		require.NoError(t, err)
+
+		entries, err := parseAll(t, parser, `2024-03-21T16:10:26.071854Z my-clb 192.0.2.104:36217 - -1 -1 -1 503 0 0 0 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.38.0" - -`+"\n")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, map[string]any{"elb": "my-clb"}, entries[0].Data)
+	})
+
+	t.Run("Subfields need expansion", func(t *testing.T) {
+		_, err := NewParser(LBTypeALB, "client_ip", FieldOptions{})
+		require.Error(t, err)
+	})
+
+	t.Run("Detection selects subfields", func(t *testing.T) {
+		filters, err := newFieldFilters("time,http_method,client_ip", FieldOptions{Expanded: true})
+		require.NoError(t, err)
+		assert.True(t, filters[LBTypeALB].IncludesName("http_method"))
+		assert.True(t, filters[LBTypeNLB].IncludesName("client_ip"))
+	})
+}
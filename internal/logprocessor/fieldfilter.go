@@ -2,15 +2,23 @@ package logprocessor
 
 import (
 	"fmt"
+	"os"
+	"slices"
 	"strings"
 )
 
-// LBType represents the type of load balancer.
+// LBType represents the type of log: a load balancer log, or another AWS log
+// delivered to S3.
 type LBType string
 
 const (
-	LBTypeALB LBType = "alb"
-	LBTypeNLB LBType = "nlb"
+	LBTypeALB        LBType = "alb"
+	LBTypeNLB        LBType = "nlb"
+	LBTypeCLB        LBType = "clb"
+	LBTypeALBConn    LBType = "alb_conn"   // ALB connection logs
+	LBTypeCloudFront LBType = "cloudfront" // CloudFront standard logs
+	LBTypeS3Access   LBType = "s3_access"  // S3 server access logs
+	LBTypeVPCFlow    LBType = "vpc_flow"   // VPC Flow Logs
 )
 
 // ALB log fields in order.
@@ -80,39 +88,154 @@ var nlbFields = []string{
 	"tls_connection_creation_time",
 }
 
+// ALB connection log fields in order. AWS documents the first field as
+// "timestamp"; it is named "time" like in the access logs.
+// https://docs.aws.amazon.com/elasticloadbalancing/latest/application/load-balancer-connection-logs.html
+var albConnFields = []string{
+	"time",
+	"client_ip",
+	"client_port",
+	"listener_port",
+	"tls_protocol",
+	"tls_cipher",
+	"tls_handshake_latency",
+	"leaf_client_cert_subject",
+	"leaf_client_cert_validity",
+	"leaf_client_cert_serial_number",
+	"tls_verify_status",
+	"conn_trace_id",
+}
+
+// Classic Load Balancer log fields in order.
+// https://docs.aws.amazon.com/elasticloadbalancing/latest/classic/access-log-collection.html
+var clbFields = []string{
+	"time",
+	"elb",
+	"client:port",
+	"backend:port",
+	"request_processing_time",
+	"backend_processing_time",
+	"response_processing_time",
+	"elb_status_code",
+	"backend_status_code",
+	"received_bytes",
+	"sent_bytes",
+	"request",
+	"user_agent",
+	"ssl_cipher",
+	"ssl_protocol",
+}
+
+// defaultExtraFields names fields past the known ones by their index.
+const defaultExtraFields = "field_%d"
+
+// knownTypes are the log types with known fields.
+var knownTypes = []LBType{LBTypeALB, LBTypeNLB, LBTypeCLB, LBTypeALBConn, LBTypeCloudFront, LBTypeS3Access, LBTypeVPCFlow}
+
+// FieldOptions configures the fields of all log types. The zero value keeps
+// the known fields as strings and names unknown trailing fields by index.
+type FieldOptions struct {
+	// ExtraFields names unknown trailing fields: a name with %d for the
+	// field index, or "drop" to leave them out (EXTRA_FIELDS).
+	ExtraFields string
+
+	// FieldNames lists the fields AWS added to a log type since, which
+	// follow its known fields (FIELD_NAMES_<TYPE>).
+	FieldNames map[LBType][]string
+
+	// TimeFields names the field with the entry timestamp of a log type
+	// (TIME_FIELD_<TYPE>).
+	TimeFields map[LBType]string
+
+	// TypeFields selects the fields of a log type, as a comma-separated
+	// list, when the type is detected per object (FIELDS_<TYPE>).
+	TypeFields map[LBType]string
+
+	// Typed converts field values to their type, see FieldFilter.value
+	// (TYPED_FIELDS).
+	Typed bool
+
+	// Expanded splits composite fields into their subfields (EXPAND_FIELDS).
+	Expanded bool
+}
+
+// fieldOptionsFromEnv reads the field options from the environment.
+func fieldOptionsFromEnv() FieldOptions {
+	opts := FieldOptions{
+		ExtraFields: os.Getenv("EXTRA_FIELDS"),
+		FieldNames:  make(map[LBType][]string),
+		TimeFields:  make(map[LBType]string),
+		TypeFields:  make(map[LBType]string),
+		Typed:       os.Getenv("TYPED_FIELDS") == "true",
+		Expanded:    os.Getenv("EXPAND_FIELDS") == "true",
+	}
+	for _, t := range knownTypes {
+		if names := splitFields(os.Getenv(fieldNamesEnv(t))); len(names) > 0 {
+			opts.FieldNames[t] = names
+		}
+		if name := os.Getenv(timeFieldEnv(t)); name != "" {
+			opts.TimeFields[t] = name
+		}
+		if config := os.Getenv(fieldsEnv(t)); config != "" {
+			opts.TypeFields[t] = config
+		}
+	}
+	return opts
+}
+
 // FieldFilter controls which log fields to include in output.
 type FieldFilter struct {
 	lbType   LBType
 	fields   []string
 	included map[string]bool
+	all      bool
+	extra    string // format of the names of unknown fields, empty to drop them
+	typed    bool   // whether values are converted to their type, see value
+	expanded bool   // whether composite fields are split, see expand
+	timeIdx  int    // index of the field with the entry timestamp, -1 for the default
 }
 
 // NewFieldFilter creates a FieldFilter for the given LB type.
-// If fieldConfig is empty, all fields are included.
-func NewFieldFilter(lbType LBType, fieldConfig string) (*FieldFilter, error) {
-	var fields []string
-	switch lbType {
-	case LBTypeALB:
-		fields = albFields
-	case LBTypeNLB:
-		fields = nlbFields
-	default:
-		return nil, fmt.Errorf("invalid load balancer type: %q (use 'alb' or 'nlb')", lbType)
+// If fieldConfig is empty, all fields are included, as well as unknown
+// trailing fields, named as configured by opts.ExtraFields.
+func NewFieldFilter(lbType LBType, fieldConfig string, opts FieldOptions) (*FieldFilter, error) {
+	fields, err := typeFields(lbType, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	extra, err := extraFieldsFormat(opts.ExtraFields)
+	if err != nil {
+		return nil, err
+	}
+
+	timeIdx, err := timeField(lbType, fields, opts.TimeFields[lbType])
+	if err != nil {
+		return nil, err
 	}
 
 	f := &FieldFilter{
 		lbType:   lbType,
 		fields:   fields,
 		included: make(map[string]bool),
+		extra:    extra,
+		typed:    opts.Typed,
+		expanded: opts.Expanded,
+		timeIdx:  timeIdx,
 	}
 
-	knownFields := make(map[string]bool, len(fields))
-	for _, name := range fields {
+	names, err := selectableFields(lbType, opts)
+	if err != nil {
+		return nil, err
+	}
+	knownFields := make(map[string]bool, len(names))
+	for _, name := range names {
 		knownFields[name] = true
 	}
 
 	if fieldConfig == "" {
 		f.included = knownFields
+		f.all = true
 		return f, nil
 	}
 
@@ -127,6 +250,55 @@ func NewFieldFilter(lbType LBType, fieldConfig string) (*FieldFilter, error) {
 	return f, nil
 }
 
+// typeFields returns the fields of the given LB type in order, followed by
+// the fields AWS added since, as named in opts.FieldNames.
+func typeFields(lbType LBType, opts FieldOptions) ([]string, error) {
+	var fields []string
+	switch lbType {
+	case LBTypeALB:
+		fields = albFields
+	case LBTypeNLB:
+		fields = nlbFields
+	case LBTypeCLB:
+		fields = clbFields
+	case LBTypeALBConn:
+		fields = albConnFields
+	case LBTypeCloudFront:
+		fields = cloudFrontFields
+	case LBTypeS3Access:
+		fields = s3AccessFields
+	case LBTypeVPCFlow:
+		fields = vpcFlowFields
+	default:
+		return nil, fmt.Errorf("invalid load balancer type: %q (use 'alb', 'nlb', 'clb', 'alb_conn', 'cloudfront', 's3_access' or 'vpc_flow')", lbType)
+	}
+
+	if names := opts.FieldNames[lbType]; len(names) > 0 {
+		fields = append(slices.Clip(fields), names...)
+	}
+	return fields, nil
+}
+
+// fieldNamesEnv returns the name of the variable naming new fields of log type t.
+func fieldNamesEnv(t LBType) string {
+	return "FIELD_NAMES_" + strings.ToUpper(string(t))
+}
+
+// extraFieldsFormat returns the format of the names of unknown trailing
+// fields from the EXTRA_FIELDS value v: a name with %d for the field index,
+// or "drop" for none.
+func extraFieldsFormat(v string) (string, error) {
+	switch {
+	case v == "":
+		return defaultExtraFields, nil
+	case v == "drop":
+		return "", nil
+	case strings.Count(v, "%") == 1 && strings.Contains(v, "%d"):
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid EXTRA_FIELDS: %q (use 'drop' or a name with %%d, e.g. %s)", v, defaultExtraFields)
+}
+
 // Name returns the field name at the given index.
 func (f *FieldFilter) Name(index int) (string, bool) {
 	if index < 0 || index >= len(f.fields) {
@@ -143,6 +315,39 @@ func (f *FieldFilter) Includes(index int) bool {
 	return f.included[f.fields[index]]
 }
 
+// field returns the name of the field at the given index and whether it
+// should be included, or is needed to expand included subfields. Fields past
+// the known ones are included under their extra name when all fields are.
+func (f *FieldFilter) field(index int) (string, bool) {
+	if index >= len(f.fields) {
+		return f.extraName(index)
+	}
+	name, ok := f.Name(index)
+	if !ok {
+		return "", false
+	}
+	if f.expanded && slices.ContainsFunc(expandedFields[name], func(sub string) bool { return f.included[sub] }) {
+		return name, true
+	}
+	return name, f.included[name]
+}
+
+// extraName returns the name of an unknown field at the given index, if
+// unknown fields are included.
+func (f *FieldFilter) extraName(index int) (string, bool) {
+	if !f.all || f.extra == "" {
+		return "", false
+	}
+	return fmt.Sprintf(f.extra, index), true
+}
+
+// IncludesName reports whether the named field should be included. Without
+// a field config this includes fields read from a log header that are not
+// known yet.
+func (f *FieldFilter) IncludesName(name string) bool {
+	return f.all || f.included[name]
+}
+
 // TotalFields returns the total number of fields for this LB type.
 func (f *FieldFilter) TotalFields() int {
 	return len(f.fields)
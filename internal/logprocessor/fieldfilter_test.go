@@ -9,7 +9,7 @@ import (
 
 func TestNewFieldFilter(t *testing.T) {
 	t.Run("ALB no fields provided includes all", func(t *testing.T) {
-		filter, err := NewFieldFilter(LBTypeALB, "")
+		filter, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		for i := range albFields {
@@ -18,7 +18,7 @@ func TestNewFieldFilter(t *testing.T) {
 	})
 
 	t.Run("NLB no fields provided includes all", func(t *testing.T) {
-		filter, err := NewFieldFilter(LBTypeNLB, "")
+		filter, err := NewFieldFilter(LBTypeNLB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		for i := range nlbFields {
@@ -26,8 +26,27 @@ func TestNewFieldFilter(t *testing.T) {
 		}
 	})
 
+	t.Run("CLB no fields provided includes all", func(t *testing.T) {
+		filter, err := NewFieldFilter(LBTypeCLB, "", FieldOptions{})
+		require.NoError(t, err)
+
+		for i := range clbFields {
+			assert.True(t, filter.Includes(i))
+		}
+	})
+
+	t.Run("ALB connection log valid fields provided", func(t *testing.T) {
+		filter, err := NewFieldFilter(LBTypeALBConn, "time,leaf_client_cert_subject,conn_trace_id", FieldOptions{})
+		require.NoError(t, err)
+
+		assert.True(t, filter.Includes(fieldIndex(albConnFields, "time")))
+		assert.True(t, filter.Includes(fieldIndex(albConnFields, "leaf_client_cert_subject")))
+		assert.True(t, filter.Includes(fieldIndex(albConnFields, "conn_trace_id")))
+		assert.False(t, filter.Includes(fieldIndex(albConnFields, "client_ip")))
+	})
+
 	t.Run("ALB valid fields provided", func(t *testing.T) {
-		filter, err := NewFieldFilter(LBTypeALB, "type,time,elb")
+		filter, err := NewFieldFilter(LBTypeALB, "type,time,elb", FieldOptions{})
 		require.NoError(t, err)
 
 		assert.True(t, filter.Includes(fieldIndex(albFields, "type")))
@@ -37,7 +56,7 @@ func TestNewFieldFilter(t *testing.T) {
 	})
 
 	t.Run("NLB valid fields provided", func(t *testing.T) {
-		filter, err := NewFieldFilter(LBTypeNLB, "type,version,time")
+		filter, err := NewFieldFilter(LBTypeNLB, "type,version,time", FieldOptions{})
 		require.NoError(t, err)
 
 		assert.True(t, filter.Includes(fieldIndex(nlbFields, "type")))
@@ -47,25 +66,30 @@ func TestNewFieldFilter(t *testing.T) {
 	})
 
 	t.Run("Invalid field provided", func(t *testing.T) {
-		_, err := NewFieldFilter(LBTypeALB, "invalid_field")
+		_, err := NewFieldFilter(LBTypeALB, "invalid_field", FieldOptions{})
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid field name")
 	})
 
 	t.Run("Invalid LB type", func(t *testing.T) {
-		_, err := NewFieldFilter("invalid", "")
+		_, err := NewFieldFilter("invalid", "", FieldOptions{})
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid load balancer type")
 	})
 
 	t.Run("ALB field on NLB fails", func(t *testing.T) {
-		_, err := NewFieldFilter(LBTypeNLB, "user_agent") // ALB-only field
+		_, err := NewFieldFilter(LBTypeNLB, "user_agent", FieldOptions{}) // ALB-only field
+		require.Error(t, err)
+	})
+
+	t.Run("ALB field on CLB fails", func(t *testing.T) {
+		_, err := NewFieldFilter(LBTypeCLB, "target:port", FieldOptions{}) // CLB calls it backend:port
 		require.Error(t, err)
 	})
 }
 
 func TestFieldFilterName(t *testing.T) {
-	filter, err := NewFieldFilter(LBTypeALB, "")
+	filter, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 	require.NoError(t, err)
 
 	t.Run("Valid index", func(t *testing.T) {
@@ -89,7 +113,7 @@ func TestFieldFilterName(t *testing.T) {
 
 func TestFieldFilterIncludes(t *testing.T) {
 	t.Run("Include all ALB fields", func(t *testing.T) {
-		filter, err := NewFieldFilter(LBTypeALB, "")
+		filter, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		for i := range albFields {
@@ -98,7 +122,7 @@ func TestFieldFilterIncludes(t *testing.T) {
 	})
 
 	t.Run("Include specific fields", func(t *testing.T) {
-		filter, err := NewFieldFilter(LBTypeALB, "type,time")
+		filter, err := NewFieldFilter(LBTypeALB, "type,time", FieldOptions{})
 		require.NoError(t, err)
 
 		assert.True(t, filter.Includes(fieldIndex(albFields, "type")))
@@ -107,7 +131,7 @@ func TestFieldFilterIncludes(t *testing.T) {
 	})
 
 	t.Run("Invalid index", func(t *testing.T) {
-		filter, err := NewFieldFilter(LBTypeALB, "")
+		filter, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		assert.False(t, filter.Includes(-1))
@@ -115,12 +139,80 @@ func TestFieldFilterIncludes(t *testing.T) {
 	})
 }
 
-func TestTotalFields(t *testing.T) {
-	albFilter, _ := NewFieldFilter(LBTypeALB, "")
-	nlbFilter, _ := NewFieldFilter(LBTypeNLB, "")
+func TestFieldFilterExtraFields(t *testing.T) {
+	t.Run("Named by index by default", func(t *testing.T) {
+		filter, err := NewFieldFilter(LBTypeNLB, "", FieldOptions{})
+		require.NoError(t, err)
+
+		name, ok := filter.field(len(nlbFields))
+		assert.True(t, ok)
+		assert.Equal(t, "field_24", name)
+	})
+
+	t.Run("Custom naming", func(t *testing.T) {
+		filter, err := NewFieldFilter(LBTypeALB, "", FieldOptions{ExtraFields: "unknown_%d"})
+		require.NoError(t, err)
 
-	assert.Equal(t, 33, albFilter.TotalFields()) // ALB has 33 fields
-	assert.Equal(t, 24, nlbFilter.TotalFields()) // NLB TLS has 24 fields
+		name, ok := filter.field(40)
+		assert.True(t, ok)
+		assert.Equal(t, "unknown_40", name)
+	})
+
+	t.Run("Excluded when fields are selected", func(t *testing.T) {
+		filter, err := NewFieldFilter(LBTypeALB, "type,time", FieldOptions{})
+		require.NoError(t, err)
+
+		_, ok := filter.field(len(albFields))
+		assert.False(t, ok)
+	})
+
+	t.Run("Invalid naming", func(t *testing.T) {
+		_, err := NewFieldFilter(LBTypeALB, "", FieldOptions{ExtraFields: "field_%s"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "EXTRA_FIELDS")
+	})
+
+	t.Run("Names of new fields from config", func(t *testing.T) {
+		filter, err := NewFieldFilter(LBTypeALB, "time,new_two", FieldOptions{FieldNames: map[LBType][]string{LBTypeALB: {"new_one", "new_two"}}})
+		require.NoError(t, err)
+
+		assert.Equal(t, len(albFields)+2, filter.TotalFields())
+		name, ok := filter.field(len(albFields) + 1)
+		assert.True(t, ok)
+		assert.Equal(t, "new_two", name)
+		assert.False(t, filter.Includes(len(albFields)))
+		assert.Len(t, albFields, 33, "built-in fields are unchanged")
+	})
+}
+
+func TestFieldOptionsFromEnv(t *testing.T) {
+	t.Setenv("EXTRA_FIELDS", "drop")
+	t.Setenv("FIELD_NAMES_ALB", "new_one, new_two")
+	t.Setenv("TIME_FIELD_NLB", "tls_connection_creation_time")
+	t.Setenv("FIELDS_CLB", "time,elb")
+	t.Setenv("TYPED_FIELDS", "true")
+	t.Setenv("EXPAND_FIELDS", "true")
+
+	assert.Equal(t, FieldOptions{
+		ExtraFields: "drop",
+		FieldNames:  map[LBType][]string{LBTypeALB: {"new_one", "new_two"}},
+		TimeFields:  map[LBType]string{LBTypeNLB: "tls_connection_creation_time"},
+		TypeFields:  map[LBType]string{LBTypeCLB: "time,elb"},
+		Typed:       true,
+		Expanded:    true,
+	}, fieldOptionsFromEnv())
+}
+
+func TestTotalFields(t *testing.T) {
+	albFilter, _ := NewFieldFilter(LBTypeALB, "", FieldOptions{})
+	nlbFilter, _ := NewFieldFilter(LBTypeNLB, "", FieldOptions{})
+	clbFilter, _ := NewFieldFilter(LBTypeCLB, "", FieldOptions{})
+	connFilter, _ := NewFieldFilter(LBTypeALBConn, "", FieldOptions{})
+
+	assert.Equal(t, 33, albFilter.TotalFields())  // ALB has 33 fields
+	assert.Equal(t, 24, nlbFilter.TotalFields())  // NLB TLS has 24 fields
+	assert.Equal(t, 15, clbFilter.TotalFields())  // CLB has 15 fields
+	assert.Equal(t, 12, connFilter.TotalFields()) // ALB connection logs have 12 fields
 }
 
 func fieldIndex(fields []string, name string) int {
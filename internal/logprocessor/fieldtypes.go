@@ -0,0 +1,203 @@
+package logprocessor
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldKind is the type of the values of a log field.
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindInt
+	kindFloat
+	kindTime
+	kindList    // comma- or space-separated strings
+	kindIntList // comma- or space-separated integers
+	kindPairs   // semicolon-separated key=value pairs
+)
+
+// fieldKinds holds the fields of each log type whose values are not strings.
+var fieldKinds = map[LBType]map[string]fieldKind{
+	LBTypeALB: {
+		"time":                     kindTime,
+		"client_port":              kindInt,
+		"target_port":              kindInt,
+		"url_port":                 kindInt,
+		"request_processing_time":  kindFloat,
+		"target_processing_time":   kindFloat,
+		"response_processing_time": kindFloat,
+		"elb_status_code":          kindInt,
+		"target_status_code":       kindInt,
+		"received_bytes":           kindInt,
+		"sent_bytes":               kindInt,
+		"matched_rule_priority":    kindInt,
+		"trace_id":                 kindPairs,
+		"request_creation_time":    kindTime,
+		"actions_executed":         kindList,
+		"target:port_list":         kindList,
+		"target_status_code_list":  kindIntList,
+	},
+	LBTypeNLB: {
+		"time":                         kindTime,
+		"client_port":                  kindInt,
+		"target_port":                  kindInt,
+		"tcp_connection_time_ms":       kindFloat,
+		"tls_handshake_time_ms":        kindFloat,
+		"received_bytes":               kindInt,
+		"sent_bytes":                   kindInt,
+		"tls_connection_creation_time": kindTime,
+	},
+	LBTypeCLB: {
+		"time":                     kindTime,
+		"client_port":              kindInt,
+		"backend_port":             kindInt,
+		"url_port":                 kindInt,
+		"request_processing_time":  kindFloat,
+		"backend_processing_time":  kindFloat,
+		"response_processing_time": kindFloat,
+		"elb_status_code":          kindInt,
+		"backend_status_code":      kindInt,
+		"received_bytes":           kindInt,
+		"sent_bytes":               kindInt,
+	},
+	LBTypeALBConn: {
+		"time":                  kindTime,
+		"client_port":           kindInt,
+		"listener_port":         kindInt,
+		"tls_handshake_latency": kindFloat,
+	},
+	LBTypeCloudFront: {
+		"sc-bytes":           kindInt,
+		"sc-status":          kindInt,
+		"cs-bytes":           kindInt,
+		"time-taken":         kindFloat,
+		"c-port":             kindInt,
+		"time-to-first-byte": kindFloat,
+		"sc-content-len":     kindInt,
+		"sc-range-start":     kindInt,
+		"sc-range-end":       kindInt,
+	},
+	LBTypeS3Access: {
+		"time":             kindTime,
+		"http_status":      kindInt,
+		"bytes_sent":       kindInt,
+		"object_size":      kindInt,
+		"total_time":       kindInt,
+		"turn_around_time": kindInt,
+	},
+	LBTypeVPCFlow: {
+		"version":   kindInt,
+		"srcport":   kindInt,
+		"dstport":   kindInt,
+		"protocol":  kindInt,
+		"packets":   kindInt,
+		"bytes":     kindInt,
+		"start":     kindInt,
+		"end":       kindInt,
+		"tcp-flags": kindInt,
+	},
+}
+
+// fieldTimeLayouts are the layouts of time fields: ELB times, NLB TLS
+// connection creation times without a zone, and S3 access log times.
+var fieldTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", s3AccessTimeLayout}
+
+// parseFieldTime parses the value of a time field.
+func parseFieldTime(raw string) (time.Time, error) {
+	var err error
+	for _, layout := range fieldTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// timeFieldEnv returns the name of the variable choosing the field with the
+// entry timestamp of log type t.
+func timeFieldEnv(t LBType) string {
+	return "TIME_FIELD_" + strings.ToUpper(string(t))
+}
+
+// timeField returns the index of the named field in fields, chosen as the
+// entry timestamp of the given log type, or -1 if name is empty and the
+// default is used. Only time fields can be chosen.
+func timeField(lbType LBType, fields []string, name string) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+	i := slices.Index(fields, name)
+	if i < 0 || fieldKinds[lbType][name] != kindTime {
+		return -1, fmt.Errorf("invalid %s: %q is not a time field of %s", timeFieldEnv(lbType), name, lbType)
+	}
+	return i, nil
+}
+
+// value returns the value of the named field: an int64, float64 or
+// time.Time for numeric and time fields, a slice for lists, a map for
+// key=value pairs, nil for the "-" placeholder and the -1 used for numbers
+// that do not apply, and the raw string otherwise. All values are strings if
+// field values are not typed. Values that do not parse as their type are
+// kept as strings.
+func (f *FieldFilter) value(name, raw string) any {
+	if !f.typed {
+		return raw
+	}
+	if raw == "-" {
+		return nil
+	}
+
+	switch fieldKinds[f.lbType][name] {
+	case kindInt:
+		if raw == "-1" {
+			return nil
+		}
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return n
+		}
+	case kindFloat:
+		if raw == "-1" {
+			return nil
+		}
+		if n, err := strconv.ParseFloat(raw, 64); err == nil {
+			return n
+		}
+	case kindTime:
+		if t, err := parseFieldTime(raw); err == nil {
+			return t
+		}
+	case kindList:
+		return splitList(raw)
+	case kindIntList:
+		list := splitList(raw)
+		values := make([]any, len(list))
+		for i, v := range list {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				values[i] = n
+			} else if v != "-" {
+				values[i] = v
+			}
+		}
+		return values
+	case kindPairs:
+		pairs := make(map[string]string)
+		for _, pair := range strings.Split(raw, ";") {
+			if key, val, _ := strings.Cut(pair, "="); key != "" {
+				pairs[key] = val
+			}
+		}
+		return pairs
+	}
+	return raw
+}
+
+// splitList splits a list of values separated by commas or spaces.
+func splitList(raw string) []string {
+	return strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -0,0 +1,105 @@
+package logprocessor
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		lbType LBType
+		field  string
+		raw    string
+		want   any
+	}{
+		{"Int", LBTypeALB, "elb_status_code", "200", int64(200)},
+		{"Float", LBTypeALB, "target_processing_time", "0.001", 0.001},
+		{"Time", LBTypeALB, "request_creation_time", "2024-03-21T16:10:26.071854Z", time.Date(2024, 3, 21, 16, 10, 26, 71854000, time.UTC)},
+		{"Time without zone", LBTypeNLB, "tls_connection_creation_time", "2024-03-21T16:10:24", time.Date(2024, 3, 21, 16, 10, 24, 0, time.UTC)},
+		{"S3 access time", LBTypeS3Access, "time", "06/Feb/2019:00:00:38 +0000", time.Date(2019, 2, 6, 0, 0, 38, 0, time.FixedZone("", 0))},
+		{"Missing", LBTypeALB, "target_status_code", "-", nil},
+		{"Missing string", LBTypeALB, "ssl_cipher", "-", nil},
+		{"Not applicable number", LBTypeALB, "target_processing_time", "-1", nil},
+		{"String", LBTypeALB, "elb", "app/my-alb/50dc6c495c0c9188", "app/my-alb/50dc6c495c0c9188"},
+		{"Unparsable number kept", LBTypeALB, "elb_status_code", "abc", "abc"},
+		{"List", LBTypeALB, "actions_executed", "waf,authenticate,forward", []string{"waf", "authenticate", "forward"}},
+		{"Space-separated list", LBTypeALB, "target:port_list", "10.0.0.1:80 10.0.0.2:80", []string{"10.0.0.1:80", "10.0.0.2:80"}},
+		{"Integer list", LBTypeALB, "target_status_code_list", "502 200", []any{int64(502), int64(200)}},
+		{"Pairs", LBTypeALB, "trace_id", "Root=1-58337262-36d228ad5d99923122bbe354;Self=1-58337262-12345678", map[string]string{"Root": "1-58337262-36d228ad5d99923122bbe354", "Self": "1-58337262-12345678"}},
+		{"Same name differs per type", LBTypeCloudFront, "time", "21:02:31", "21:02:31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFieldFilter(tt.lbType, "", FieldOptions{Typed: true})
+			require.NoError(t, err)
+			got := f.value(tt.field, tt.raw)
+			if want, ok := tt.want.(time.Time); ok {
+				require.IsType(t, time.Time{}, got)
+				assert.True(t, want.Equal(got.(time.Time)), "got %v", got)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("Strings by default", func(t *testing.T) {
+		f, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "200", f.value("elb_status_code", "200"))
+		assert.Equal(t, "-", f.value("target_status_code", "-"))
+	})
+}
+
+func TestTypedEntryJSON(t *testing.T) {
+	parser, err := NewParser(LBTypeCLB, "elb_status_code,backend_status_code,backend_processing_time,sent_bytes,ssl_cipher", FieldOptions{Typed: true})
+	require.NoError(t, err)
+
+	record := `2024-03-21T16:10:26.071854Z my-clb 192.0.2.104:36217 - 0.000073 -1 -1 504 0 0 29 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.38.0" - -`
+	entries, err := parseAll(t, parser, record+"\n")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	data, err := json.Marshal(entries[0].Data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"backend_processing_time":null,"backend_status_code":0,"elb_status_code":504,"sent_bytes":29,"ssl_cipher":null}`, string(data))
+}
+
+func TestMultiValuedEntryJSON(t *testing.T) {
+	record := strings.NewReplacer(`"forward"`, `"waf,forward"`, `"10.0.0.24:8080" "200"`, `"10.0.0.24:8080 10.0.0.25:8080" "502 200"`).Replace(albRequestRecord)
+	entryJSON := func(t *testing.T, opts FieldOptions) string {
+		parser, err := NewParser(LBTypeALB, "trace_id,actions_executed,target:port_list,target_status_code_list", opts)
+		require.NoError(t, err)
+
+		entries, err := parseAll(t, parser, record+"\n")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		data, err := json.Marshal(entries[0].Data)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("Strings by default", func(t *testing.T) {
+		assert.JSONEq(t, `{
+			"trace_id": "Root=1-58337262-36d228ad5d99923122bbe354",
+			"actions_executed": "waf,forward",
+			"target:port_list": "10.0.0.24:8080 10.0.0.25:8080",
+			"target_status_code_list": "502 200"
+		}`, entryJSON(t, FieldOptions{}))
+	})
+
+	t.Run("Typed", func(t *testing.T) {
+		assert.JSONEq(t, `{
+			"trace_id": {"Root": "1-58337262-36d228ad5d99923122bbe354"},
+			"actions_executed": ["waf", "forward"],
+			"target:port_list": ["10.0.0.24:8080", "10.0.0.25:8080"],
+			"target_status_code_list": [502, 200]
+		}`, entryJSON(t, FieldOptions{Typed: true}))
+	})
+}
@@ -0,0 +1,72 @@
+package logprocessor
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/ledger"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessLogsLedger(t *testing.T) {
+	obj := types.S3ObjectInfo{Bucket: "test-bucket", Key: "logs/test.log.gz"}
+
+	l, err := ledger.OpenFile(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	require.NoError(t, err)
+
+	mockS3 := new(MockS3API)
+	mockDest := &MockDestination{}
+
+	// Same object and ETag twice, then overwritten with a new ETag
+	for _, etag := range []string{`"v1"`, `"v1"`, `"v2"`} {
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+			ETag: aws.String(etag),
+		}, nil).Once()
+	}
+
+	lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+	lp.ledger = l
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, lp.ProcessLogs(context.Background(), obj))
+	}
+
+	assert.Len(t, mockDest.Entries(), 10, "second delivery of v1 is skipped")
+
+	seen, err := l.Seen(obj, `"v2"`)
+	require.NoError(t, err)
+	assert.True(t, seen)
+	mockS3.AssertExpectations(t)
+}
+
+func TestProcessLogsLedgerFailedDelivery(t *testing.T) {
+	obj := types.S3ObjectInfo{Bucket: "test-bucket", Key: "logs/test.log.gz"}
+
+	l, err := ledger.OpenFile(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	require.NoError(t, err)
+
+	mockS3 := new(MockS3API)
+	mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(loadTestData(t)),
+		ETag: aws.String(`"v1"`),
+	}, nil).Once()
+
+	lp := NewWithDeps(mockS3, nil, []destinations.Destination{&MockDestination{}, &MockDestination{err: assert.AnError}})
+	lp.ledger = l
+
+	require.ErrorIs(t, lp.ProcessLogs(context.Background(), obj), assert.AnError)
+
+	// The object is forwarded again on retry
+	seen, err := l.Seen(obj, `"v1"`)
+	require.NoError(t, err)
+	assert.False(t, seen)
+}
@@ -0,0 +1,236 @@
+package logprocessor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/pgzip"
+	"golang.org/x/sync/errgroup"
+)
+
+// stdinInput is the input argument that reads a log file from standard input.
+const stdinInput = "-"
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// HandleInput processes logs from an S3 URL, a local file or directory
+// (optionally as a file:// URL), a glob pattern, or "-" for stdin (CLI mode).
+func (p *LogProcessor) HandleInput(ctx context.Context, input string, opts BackfillOptions) error {
+	return p.HandleInputs(ctx, []string{input}, nil, opts)
+}
+
+// HandleInputs processes several inputs (see HandleInput) and the objects
+// listed in manifests (see queueManifest) on one bounded pool of workers.
+func (p *LogProcessor) HandleInputs(ctx context.Context, inputs, manifests []string, opts BackfillOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	wp := newWorkPool(ctx)
+	for _, input := range inputs {
+		if err := p.queueInput(wp, input, opts); err != nil {
+			wp.cancel()
+			wp.wait()
+			return err
+		}
+	}
+	for _, manifest := range manifests {
+		p.queueManifest(wp, manifest, opts)
+	}
+	return wp.wait()
+}
+
+func (p *LogProcessor) queueInput(wp *workPool, input string, opts BackfillOptions) error {
+	switch {
+	case strings.HasPrefix(input, "s3://"):
+		return p.queueS3URL(wp, input, opts)
+	case input == stdinInput:
+		wp.work(func(ctx context.Context) error {
+			return p.processStream(ctx, "stdin", os.Stdin)
+		})
+		return nil
+	}
+
+	paths, err := localPaths(strings.TrimPrefix(input, "file://"))
+	if err != nil {
+		return err
+	}
+
+	cp := opts.Checkpoint
+
+	for _, path := range paths {
+		if !opts.matches(path) || cp.isCompleted(path) {
+			continue
+		}
+		wp.work(func(ctx context.Context) error {
+			if err := p.processFile(ctx, path); err != nil {
+				return err
+			}
+			cp.markCompleted(path)
+			return nil
+		})
+	}
+	return nil
+}
+
+// workPool runs the objects of a CLI run on a bounded set of workers, and
+// the listings that queue them on a bounded set of listers. Both run in one
+// group, so the first error of either cancels all of them. Listers and
+// workers have separate limits, so a lister never waits on a worker slot it
+// holds.
+type workPool struct {
+	group   *errgroup.Group
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers chan struct{}
+	listers chan struct{}
+}
+
+func newWorkPool(ctx context.Context) *workPool {
+	ctx, cancel := context.WithCancel(ctx)
+	group, ctx := errgroup.WithContext(ctx)
+	return &workPool{
+		group:   group,
+		ctx:     ctx,
+		cancel:  cancel,
+		workers: make(chan struct{}, maxConcurrency),
+		listers: make(chan struct{}, maxConcurrency),
+	}
+}
+
+// work runs fn on a worker, blocking while all workers are busy.
+func (wp *workPool) work(fn func(ctx context.Context) error) {
+	wp.run(wp.workers, fn)
+}
+
+// list runs fn, which typically queues work, on a lister.
+func (wp *workPool) list(fn func(ctx context.Context) error) {
+	wp.run(wp.listers, fn)
+}
+
+// run runs fn in the group once one of slots is free. Once the pool is
+// canceled fn is not run, and the group fails with the cancellation.
+func (wp *workPool) run(slots chan struct{}, fn func(ctx context.Context) error) {
+	if wp.ctx.Err() == nil {
+		select {
+		case slots <- struct{}{}:
+			wp.group.Go(func() error {
+				defer func() { <-slots }()
+				return fn(wp.ctx)
+			})
+			return
+		case <-wp.ctx.Done():
+		}
+	}
+	wp.group.Go(wp.ctx.Err)
+}
+
+// wait waits for all listers and workers and returns the first error.
+func (wp *workPool) wait() error {
+	defer wp.cancel()
+	return wp.group.Wait()
+}
+
+func (p *LogProcessor) processFile(ctx context.Context, path string) error {
+	if isELBTestFile(path) {
+		slog.Info("skipping ELB test file", "path", path)
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		err = fmt.Errorf("open: %w", err)
+	} else {
+		defer f.Close()
+		err = p.processStream(ctx, path, f)
+	}
+	if err != nil {
+		err = fmt.Errorf("%s: %w", path, err)
+		slog.Error("processing failed", "error", err)
+		return err
+	}
+	return nil
+}
+
+// processStream forwards a gzipped or plain log stream.
+func (p *LogProcessor) processStream(ctx context.Context, name string, r io.Reader) error {
+	slog.Info("processing", "path", name)
+
+	rc, err := maybeGunzip(r)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	stats, err := p.forward(ctx, rc, p.parserFor(name), name)
+	if err != nil {
+		return err
+	}
+	p.warnMismatch(stats, "path", name)
+
+	slog.Info("completed", "path", name, "entries", stats.Records, "rejected", stats.Rejected)
+	return nil
+}
+
+// maybeGunzip returns a decompressing reader if r starts with the gzip magic
+// bytes, or r itself otherwise, whatever the file is named. Blocks are
+// decompressed ahead of the reader on another goroutine, and concatenated
+// gzip members are read as one stream. Closing the reader stops
+// decompression but does not close r.
+func maybeGunzip(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return io.NopCloser(br), nil
+	}
+
+	gr, err := pgzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("gzip reader: %w", err)
+	}
+	return gr, nil
+}
+
+// localPaths expands a file, directory (walked recursively) or glob pattern
+// into the list of files to process.
+func localPaths(pattern string) ([]string, error) {
+	matches := []string{pattern}
+	if strings.ContainsAny(pattern, "*?[") {
+		var err error
+		matches, err = filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern: %w", err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", pattern)
+		}
+	}
+
+	var paths []string
+	for _, match := range matches {
+		err := filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walk %s: %w", match, err)
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,185 @@
+package logprocessor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLogFiles creates a directory tree with a gzipped and a plain copy of the sample log.
+func writeLogFiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	plain, err := os.ReadFile("testdata/sample.log")
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2024", "03", "21"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2024", "03", "21", "a.log.gz"), gzipData(t, plain).Bytes(), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2024", "03", "21", "b.log"), plain, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ELBAccessLogTestFile"), []byte("test"), 0o644))
+	return dir
+}
+
+func TestHandleInput(t *testing.T) {
+	t.Run("Directory is walked recursively", func(t *testing.T) {
+		dir := writeLogFiles(t)
+		mockDest := &MockDestination{}
+		lp := NewWithDeps(nil, nil, []destinations.Destination{mockDest})
+
+		err := lp.HandleInput(context.Background(), dir, BackfillOptions{})
+		require.NoError(t, err)
+		assert.Len(t, mockDest.Entries(), 10)
+	})
+
+	t.Run("File URL", func(t *testing.T) {
+		dir := writeLogFiles(t)
+		mockDest := &MockDestination{}
+		lp := NewWithDeps(nil, nil, []destinations.Destination{mockDest})
+
+		err := lp.HandleInput(context.Background(), "file://"+filepath.Join(dir, "2024", "03", "21", "a.log.gz"), BackfillOptions{})
+		require.NoError(t, err)
+		assert.Len(t, mockDest.Entries(), 5)
+	})
+
+	t.Run("Glob pattern", func(t *testing.T) {
+		dir := writeLogFiles(t)
+		mockDest := &MockDestination{}
+		lp := NewWithDeps(nil, nil, []destinations.Destination{mockDest})
+
+		err := lp.HandleInput(context.Background(), filepath.Join(dir, "2024", "*", "*", "*.log"), BackfillOptions{})
+		require.NoError(t, err)
+		assert.Len(t, mockDest.Entries(), 5)
+	})
+
+	t.Run("Glob without matches", func(t *testing.T) {
+		lp := NewWithDeps(nil, nil, []destinations.Destination{&MockDestination{}})
+
+		err := lp.HandleInput(context.Background(), filepath.Join(t.TempDir(), "*.log"), BackfillOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no files match")
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		lp := NewWithDeps(nil, nil, []destinations.Destination{&MockDestination{}})
+
+		err := lp.HandleInput(context.Background(), filepath.Join(t.TempDir(), "missing.log"), BackfillOptions{})
+		require.Error(t, err)
+	})
+}
+
+func TestProcessStream(t *testing.T) {
+	plain, err := os.ReadFile("testdata/sample.log")
+	require.NoError(t, err)
+
+	t.Run("Plain stream", func(t *testing.T) {
+		mockDest := &MockDestination{}
+		lp := NewWithDeps(nil, nil, []destinations.Destination{mockDest})
+
+		err := lp.processStream(context.Background(), "stdin", strings.NewReader(string(plain)))
+		require.NoError(t, err)
+		assert.Len(t, mockDest.Entries(), 5)
+	})
+
+	t.Run("Gzipped stream", func(t *testing.T) {
+		mockDest := &MockDestination{}
+		lp := NewWithDeps(nil, nil, []destinations.Destination{mockDest})
+
+		err := lp.processStream(context.Background(), "stdin", gzipData(t, plain))
+		require.NoError(t, err)
+		assert.Len(t, mockDest.Entries(), 5)
+	})
+
+	t.Run("Multi-member gzip stream", func(t *testing.T) {
+		mockDest := &MockDestination{}
+		lp := NewWithDeps(nil, nil, []destinations.Destination{mockDest})
+
+		// Large enough for several decompressed blocks, as written by tools
+		// that concatenate gzip files
+		var stream bytes.Buffer
+		stream.Write(gzipData(t, bytes.Repeat(plain, 1000)).Bytes())
+		stream.Write(gzipData(t, plain).Bytes())
+		stream.Write(gzipData(t, plain).Bytes())
+
+		err := lp.processStream(context.Background(), "stdin", &stream)
+		require.NoError(t, err)
+		assert.Len(t, mockDest.Entries(), 5*1002)
+	})
+
+	t.Run("Empty stream", func(t *testing.T) {
+		mockDest := &MockDestination{}
+		lp := NewWithDeps(nil, nil, []destinations.Destination{mockDest})
+
+		err := lp.processStream(context.Background(), "stdin", strings.NewReader(""))
+		require.NoError(t, err)
+		assert.Empty(t, mockDest.Entries())
+	})
+}
+
+// cancelingDestination cancels the run once it receives its first entry.
+type cancelingDestination struct {
+	cancel context.CancelFunc
+}
+
+func (d *cancelingDestination) SendLogs(ctx context.Context, entries <-chan types.LogEntry) error {
+	for range entries {
+		d.cancel()
+	}
+	return nil
+}
+
+func TestProcessStreamCanceled(t *testing.T) {
+	plain, err := os.ReadFile("testdata/sample.log")
+	require.NoError(t, err)
+
+	// The gzip reader is closed when processStream returns, which must not
+	// happen while the parser still reads from it (run with -race)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lp := NewWithDeps(nil, nil, []destinations.Destination{&cancelingDestination{cancel: cancel}})
+
+	err = lp.processStream(ctx, "stdin", gzipData(t, bytes.Repeat(plain, 2000)))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWorkPool(t *testing.T) {
+	t.Run("Lister error cancels workers", func(t *testing.T) {
+		wp := newWorkPool(context.Background())
+
+		started := make(chan struct{})
+		wp.work(func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		<-started
+		wp.list(func(ctx context.Context) error {
+			return assert.AnError
+		})
+
+		require.ErrorIs(t, wp.wait(), assert.AnError)
+	})
+
+	t.Run("Work queued after cancellation is not run", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		wp := newWorkPool(ctx)
+
+		ran := false
+		wp.work(func(ctx context.Context) error {
+			ran = true
+			return nil
+		})
+
+		require.ErrorIs(t, wp.wait(), context.Canceled)
+		assert.False(t, ran)
+	})
+}
@@ -1,25 +1,24 @@
 package logprocessor
 
 import (
-	"compress/gzip"
 	"context"
-	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/ledger"
 	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
-	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -31,13 +30,24 @@ const (
 type S3API interface {
 	GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error)
 	ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
+	GetObjectTagging(input *s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error)
+	PutObjectTagging(input *s3.PutObjectTaggingInput) (*s3.PutObjectTaggingOutput, error)
+	CopyObject(input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error)
+	DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error)
+	HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
+	RestoreObject(input *s3.RestoreObjectInput) (*s3.RestoreObjectOutput, error)
+	PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error)
 }
 
 // LogProcessor processes load balancer log files from S3 and sends them to configured destinations.
 type LogProcessor struct {
 	s3           S3API
-	fields       *FieldFilter
+	parser       Parser
 	destinations []destinations.Destination
+	ledger       ledger.Ledger
+	post         PostProcess
+	recordErrors RecordErrors
+	metrics      *Metrics
 	bufferSize   int
 }
 
@@ -48,7 +58,12 @@ func New(sess *session.Session) (*LogProcessor, error) {
 		lbType = LBTypeALB // default to ALB for backwards compatibility
 	}
 
-	fields, err := NewFieldFilter(lbType, os.Getenv("FIELDS"))
+	fieldConfig := os.Getenv(fieldsEnv(lbType))
+	if fieldConfig == "" {
+		fieldConfig = os.Getenv("FIELDS")
+	}
+
+	parser, err := NewParser(lbType, fieldConfig, fieldOptionsFromEnv())
 	if err != nil {
 		return nil, fmt.Errorf("invalid fields config: %w", err)
 	}
@@ -58,6 +73,21 @@ func New(sess *session.Session) (*LogProcessor, error) {
 		return nil, fmt.Errorf("invalid destinations config: %w", err)
 	}
 
+	led, err := ledger.New(os.Getenv("LEDGER"), sess)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ledger config: %w", err)
+	}
+
+	post, err := postProcessFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	recordErrors, err := recordErrorsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
 	bufferSize := defaultBufferSize
 	if v := os.Getenv("BUFFER_SIZE"); v != "" {
 		bufferSize, err = strconv.Atoi(v)
@@ -68,79 +98,156 @@ func New(sess *session.Session) (*LogProcessor, error) {
 
 	return &LogProcessor{
 		s3:           s3.New(sess),
-		fields:       fields,
+		parser:       parser,
 		destinations: dests,
+		ledger:       led,
+		post:         post,
+		recordErrors: recordErrors,
+		metrics:      metricsFromEnv(),
 		bufferSize:   bufferSize,
 	}, nil
 }
 
 // NewWithDeps creates a LogProcessor with explicit dependencies (for testing).
-func NewWithDeps(s3Client S3API, fields *FieldFilter, dests []destinations.Destination) *LogProcessor {
-	if fields == nil {
-		fields, _ = NewFieldFilter(LBTypeALB, "")
+func NewWithDeps(s3Client S3API, parser Parser, dests []destinations.Destination) *LogProcessor {
+	if parser == nil {
+		parser, _ = NewParser(LBTypeALB, "", FieldOptions{})
+	}
+	return &LogProcessor{
+		s3:           s3Client,
+		parser:       parser,
+		destinations: dests,
+		recordErrors: RecordErrors{Policy: RecordErrorsSkip},
+		bufferSize:   defaultBufferSize,
 	}
-	return &LogProcessor{s3: s3Client, fields: fields, destinations: dests, bufferSize: defaultBufferSize}
 }
 
 // HandleLambdaEvent processes S3 object creation events from Lambda.
 func (p *LogProcessor) HandleLambdaEvent(ctx context.Context, event events.S3Event) error {
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(maxConcurrency)
+	objs, err := s3EventObjects(event)
+	if err != nil {
+		return err
+	}
+	return p.processObjects(ctx, objs)
+}
 
-	for _, r := range event.Records {
-		obj := types.S3ObjectInfo{
-			Bucket: r.S3.Bucket.Name,
-			Key:    r.S3.Object.Key,
-		}
-		g.Go(func() error {
-			return p.processObject(ctx, obj)
-		})
+// HandleS3URL processes all objects matching an S3 URL prefix (CLI mode).
+// With a time window the prefix is expanded into the date prefixes of the ELB
+// key layout, which are listed in parallel.
+func (p *LogProcessor) HandleS3URL(ctx context.Context, url string, opts BackfillOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
 	}
 
-	return g.Wait()
+	wp := newWorkPool(ctx)
+	if err := p.queueS3URL(wp, url, opts); err != nil {
+		wp.cancel()
+		wp.wait()
+		return err
+	}
+	return wp.wait()
 }
 
-// HandleS3URL processes all objects matching an S3 URL prefix (CLI mode).
-func (p *LogProcessor) HandleS3URL(ctx context.Context, url string) error {
+// queueS3URL lists the objects matching an S3 URL prefix and queues them on wp.
+func (p *LogProcessor) queueS3URL(wp *workPool, url string, opts BackfillOptions) error {
 	bucket, prefix, err := parseS3URL(url)
 	if err != nil {
 		return fmt.Errorf("parse S3 URL: %w", err)
 	}
 
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(maxConcurrency)
+	prefixes, err := opts.prefixes(prefix)
+	if err != nil {
+		return err
+	}
 
-	var token *string
-	for {
-		resp, err := p.s3.ListObjectsV2(&s3.ListObjectsV2Input{
-			Bucket:            aws.String(bucket),
-			Prefix:            aws.String(prefix),
-			ContinuationToken: token,
+	cp := opts.Checkpoint
+
+	for _, prefix := range prefixes {
+		wp.list(func(ctx context.Context) error {
+			return p.listObjects(ctx, bucket, prefix, cp.startAfter(bucket, prefix), func(item *s3.Object) {
+				key := *item.Key
+				if completed := cp.listed(bucket, prefix, key); completed || !opts.matches(key) {
+					cp.done(bucket, prefix, key)
+					return
+				}
+				obj := types.S3ObjectInfo{
+					Bucket: bucket,
+					Key:    key,
+				}
+
+				// Archived objects stay pending in the checkpoint, so a later run
+				// picks them up once restored
+				storageClass := aws.StringValue(item.StorageClass)
+				if archiveStorageClasses[storageClass] && !opts.Archived.restoring() {
+					opts.Archived.skip(obj, storageClass)
+					return
+				}
+
+				wp.work(func(ctx context.Context) error {
+					if mayBeArchived(storageClass) {
+						restored, err := p.restore(opts.Archived, obj, storageClass)
+						if err != nil || !restored {
+							return err
+						}
+					}
+					if err := p.processObject(ctx, obj); err != nil {
+						return err
+					}
+					cp.done(bucket, prefix, key)
+					return nil
+				})
+			})
 		})
+	}
+	return nil
+}
+
+// listObjects calls fn for every object under prefix, in key order, starting
+// after the given key (if any).
+func (p *LogProcessor) listObjects(ctx context.Context, bucket, prefix, startAfter string, fn func(*s3.Object)) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	if startAfter != "" {
+		input.StartAfter = aws.String(startAfter)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		resp, err := p.s3.ListObjectsV2(input)
 		if err != nil {
 			return fmt.Errorf("list objects: %w", err)
 		}
 
 		for _, item := range resp.Contents {
-			obj := types.S3ObjectInfo{
-				Bucket: bucket,
-				Key:    *item.Key,
-			}
-			g.Go(func() error {
-				return p.processObject(ctx, obj)
-			})
+			fn(item)
 		}
 
 		if resp.IsTruncated == nil || !*resp.IsTruncated {
-			break
+			return nil
 		}
-		token = resp.NextContinuationToken
+		input.ContinuationToken = resp.NextContinuationToken
 	}
-
-	return g.Wait()
 }
 
 func (p *LogProcessor) processObject(ctx context.Context, obj types.S3ObjectInfo) error {
+	if isELBTestFile(obj.Key) {
+		slog.Info("skipping ELB test file", "bucket", obj.Bucket, "key", obj.Key)
+		return nil
+	}
+	if strings.HasSuffix(obj.Key, deadLetterSuffix) {
+		slog.Info("skipping dead letter object", "bucket", obj.Bucket, "key", obj.Key)
+		return nil
+	}
+	if p.post.isArchived(obj) {
+		slog.Info("skipping archived object", "bucket", obj.Bucket, "key", obj.Key)
+		return nil
+	}
+
 	if err := p.ProcessLogs(ctx, obj); err != nil {
 		err = fmt.Errorf("s3://%s/%s: %w", obj.Bucket, obj.Key, err)
 		slog.Error("processing failed", "error", err)
@@ -162,49 +269,105 @@ func (p *LogProcessor) ProcessLogs(ctx context.Context, obj types.S3ObjectInfo)
 	}
 	defer resp.Body.Close()
 
-	pr, pw := io.Pipe()
-
-	go func() {
-		defer pw.Close()
-		gr, err := gzip.NewReader(resp.Body)
+	// The ETag is known from the response headers, before the body is read
+	etag := aws.StringValue(resp.ETag)
+	if p.ledger != nil && etag != "" {
+		seen, err := p.ledger.Seen(obj, etag)
 		if err != nil {
-			pw.CloseWithError(fmt.Errorf("gzip reader: %w", err))
-			return
+			return err
 		}
-		defer gr.Close()
-		if _, err := io.Copy(pw, gr); err != nil {
-			pw.CloseWithError(fmt.Errorf("decompress: %w", err))
+		if seen {
+			slog.Info("already forwarded, skipping", "bucket", obj.Bucket, "key", obj.Key, "etag", etag)
+			return nil
 		}
-	}()
+	}
+
+	// ALB and NLB logs are gzipped, Classic Load Balancer logs are not
+	r, err := maybeGunzip(resp.Body)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	stats, err := p.forward(ctx, r, p.parserFor(obj.Key), objectID(obj.Bucket, obj.Key))
+	if err != nil {
+		return err
+	}
+	p.warnMismatch(stats, "bucket", obj.Bucket, "key", obj.Key)
 
+	if p.ledger != nil && etag != "" {
+		// Every destination accepted the entries; failing here would only cause
+		// duplicates on retry
+		if err := p.ledger.Record(obj, etag); err != nil {
+			slog.Error("ledger record failed", "bucket", obj.Bucket, "key", obj.Key, "error", err)
+		}
+	}
+
+	// An object whose records were all rejected is kept for inspection
+	if stats.Records == 0 && stats.Rejected > 0 {
+		slog.Warn("all records rejected, skipping post-processing", "bucket", obj.Bucket, "key", obj.Key, "rejected", stats.Rejected)
+	} else if err := p.postProcess(obj, etag); err != nil {
+		slog.Error("post-processing failed", "bucket", obj.Bucket, "key", obj.Key, "action", p.post.Action, "error", err)
+	}
+
+	slog.Info("completed", "bucket", obj.Bucket, "key", obj.Key, "entries", stats.Records, "rejected", stats.Rejected)
+	return nil
+}
+
+// forward parses log records from r and fans them out to all destinations.
+// Records that cannot be parsed are handled by the record error policy, with
+// source locating them. It returns the parse statistics, and an error if r
+// could not be parsed, a destination failed to deliver entries, or ctx was
+// done before all entries were sent.
+func (p *LogProcessor) forward(ctx context.Context, r io.Reader, parser Parser, source string) (ParseStats, error) {
 	// Create a channel per destination for fan-out (each destination receives all entries)
 	channels := make([]chan types.LogEntry, len(p.destinations))
+	sendErrs := make([]error, len(p.destinations))
 	var wg sync.WaitGroup
 	for i, d := range p.destinations {
 		ch := make(chan types.LogEntry, p.bufferSize)
 		channels[i] = ch
 		wg.Add(1)
-		go func(d destinations.Destination, ch <-chan types.LogEntry) {
+		go func(i int, d destinations.Destination, ch <-chan types.LogEntry) {
 			defer wg.Done()
-			d.SendLogs(ctx, ch)
-		}(d, ch)
+			sendErrs[i] = d.SendLogs(ctx, ch)
+		}(i, d, ch)
 	}
 
 	// Parse records and fan out to all destination channels
 	entries := make(chan types.LogEntry, p.bufferSize)
-	go func() {
-		if err := p.parseRecords(pr, entries); err != nil {
-			slog.Error("parse failed", "error", err)
+	var stats ParseStats
+	var parseErr error
+	var rejected deadLetterBuffer
+	reject := func(line int, raw string, err error) error {
+		switch p.recordErrors.Policy {
+		case RecordErrorsFail:
+			return fmt.Errorf("line %d: %w", line, err)
+		case RecordErrorsDeadLetter:
+			rejected.add(rejectedRecord{Source: source, Line: line, Error: err.Error(), Raw: raw})
+		default:
+			slog.Warn("skipping malformed record", "source", source, "line", line, "error", err)
 		}
+		return nil
+	}
+	parsed := make(chan struct{})
+	go func() {
+		defer close(parsed)
+		stats, parseErr = parser.Parse(ctxReader{ctx: ctx, r: r}, entries, reject)
 		close(entries)
 	}()
 
-	// Fan out: send each entry to all destination channels
-	var count int
+	// Fan out: send each entry to all destination channels. Destinations stop
+	// reading once ctx is done, so stop sending then as well.
 	for entry := range entries {
-		count++
+		if ctx.Err() != nil {
+			break
+		}
 		for _, ch := range channels {
-			ch <- entry
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+			}
 		}
 	}
 
@@ -214,67 +377,56 @@ func (p *LogProcessor) ProcessLogs(ctx context.Context, obj types.S3ObjectInfo)
 	}
 	wg.Wait()
 
-	slog.Info("completed", "bucket", obj.Bucket, "key", obj.Key, "entries", count)
-	return nil
-}
-
-func (p *LogProcessor) parseRecords(r io.Reader, out chan<- types.LogEntry) error {
-	cr := csv.NewReader(r)
-	cr.Comma = ' '
-	cr.FieldsPerRecord = -1 // Allow variable field count for forward compatibility
-
-	expectedFields := p.fields.TotalFields()
-	firstRecord := true
-
-	for {
-		record, err := cr.Read()
-		if err == io.EOF {
-			return nil
-		}
-		if err != nil {
-			return fmt.Errorf("read record: %w", err)
-		}
-
-		if firstRecord {
-			slog.Info("log format", "fields", len(record), "expected", expectedFields)
-			firstRecord = false
+	if err := ctx.Err(); err != nil {
+		// Reads fail once ctx is done, so the parser stops soon. Wait for it
+		// before returning, as the caller closes r.
+		for range entries {
 		}
+		<-parsed
+		return ParseStats{}, err
+	}
 
-		entry, err := p.recordToEntry(record)
-		if err != nil {
-			return err
+	if parseErr != nil {
+		return stats, fmt.Errorf("parse %s: %w", source, parseErr)
+	}
+	if err := errors.Join(sendErrs...); err != nil {
+		return stats, fmt.Errorf("send %s: %w", source, err)
+	}
+	if len(rejected.records) > 0 {
+		if err := p.sendDeadLetter(source, &rejected); err != nil {
+			return stats, err
 		}
-		out <- entry
 	}
+	return stats, nil
 }
 
-func (p *LogProcessor) recordToEntry(record []string) (types.LogEntry, error) {
-	// Time field is at index 1 for ALB, index 2 for NLB
-	timeIdx := 1
-	if p.fields.LBType() == LBTypeNLB {
-		timeIdx = 2
-	}
-
-	// Only require enough fields to extract the timestamp
-	if len(record) <= timeIdx {
-		return types.LogEntry{}, fmt.Errorf("record too short: need at least %d fields for timestamp, got %d", timeIdx+1, len(record))
-	}
+// ctxReader is a reader whose reads fail once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
 
-	ts, err := time.Parse(time.RFC3339, record[timeIdx])
-	if err != nil {
-		return types.LogEntry{}, fmt.Errorf("parse timestamp: %w", err)
+func (r ctxReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
+	return r.r.Read(b)
+}
 
-	// Process whatever fields exist, skip missing ones
-	data := make(map[string]string)
-	for i, val := range record {
-		if p.fields.Includes(i) {
-			name, _ := p.fields.Name(i)
-			data[name] = val
-		}
+// warnMismatch reports records whose number of fields differs from the known
+// fields, logging the source given as key-value pairs.
+func (p *LogProcessor) warnMismatch(stats ParseStats, source ...any) {
+	if stats.Mismatched == 0 {
+		return
 	}
+	slog.Warn("field count mismatch, log format may have changed", append(source, "type", stats.Type, "records", stats.Mismatched)...)
+	p.metrics.count("FieldCountMismatch", stats.Mismatched, map[string]string{"LogType": string(stats.Type)})
+}
 
-	return types.LogEntry{Data: data, Timestamp: ts}, nil
+// isELBTestFile reports whether key refers to the test file ELB writes when
+// access logging is enabled, which holds no log entries.
+func isELBTestFile(key string) bool {
+	return path.Base(key) == "ELBAccessLogTestFile"
 }
 
 func parseS3URL(url string) (bucket, prefix string, err error) {
@@ -36,6 +36,41 @@ func (m *MockS3API) ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjects
 	return args.Get(0).(*s3.ListObjectsV2Output), args.Error(1)
 }
 
+func (m *MockS3API) GetObjectTagging(input *s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.GetObjectTaggingOutput), args.Error(1)
+}
+
+func (m *MockS3API) PutObjectTagging(input *s3.PutObjectTaggingInput) (*s3.PutObjectTaggingOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.PutObjectTaggingOutput), args.Error(1)
+}
+
+func (m *MockS3API) CopyObject(input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.CopyObjectOutput), args.Error(1)
+}
+
+func (m *MockS3API) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.DeleteObjectOutput), args.Error(1)
+}
+
+func (m *MockS3API) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.HeadObjectOutput), args.Error(1)
+}
+
+func (m *MockS3API) RestoreObject(input *s3.RestoreObjectInput) (*s3.RestoreObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.RestoreObjectOutput), args.Error(1)
+}
+
+func (m *MockS3API) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.PutObjectOutput), args.Error(1)
+}
+
 func TestProcessLogs(t *testing.T) {
 	t.Run("Successful Processing", func(t *testing.T) {
 		mockS3 := new(MockS3API)
@@ -52,12 +87,12 @@ func TestProcessLogs(t *testing.T) {
 			Body: io.NopCloser(&buf),
 		}, nil)
 
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		lp := &LogProcessor{
 			s3:           mockS3,
-			fields:       fields,
+			parser:       &elbParser{fields: fields},
 			destinations: []destinations.Destination{destinations.NewStdout()},
 		}
 
@@ -65,22 +100,93 @@ func TestProcessLogs(t *testing.T) {
 		require.NoError(t, err)
 		mockS3.AssertExpectations(t)
 	})
+
+	t.Run("ALB connection log", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		mockBody := `2023-10-04T17:34:06.118354Z 203.0.113.1 57012 443 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 4 "CN=client.example.com,O=Example" NotBefore=2023-09-21T22:43:21Z;NotAfter=2026-06-17T22:43:21Z FEF257D1AE2E3D96 Success TID_1e7b6e3b0b6d4ab8a5d5d2bbd5ba7f7e`
+
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(gzipData(t, []byte(mockBody))),
+		}, nil)
+
+		fields, err := NewFieldFilter(LBTypeALBConn, "", FieldOptions{})
+		require.NoError(t, err)
+
+		lp := NewWithDeps(mockS3, &elbParser{fields: fields}, []destinations.Destination{mockDest})
+
+		err = lp.ProcessLogs(context.Background(), types.S3ObjectInfo{Bucket: "test-bucket", Key: "conn_log.test-key.log.gz"})
+		require.NoError(t, err)
+
+		entries := mockDest.Entries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, time.Date(2023, 10, 4, 17, 34, 6, 118354000, time.UTC), entries[0].Timestamp)
+		assert.Equal(t, "CN=client.example.com,O=Example", entries[0].Data["leaf_client_cert_subject"])
+		assert.Equal(t, "Success", entries[0].Data["tls_verify_status"])
+		assert.Equal(t, "TID_1e7b6e3b0b6d4ab8a5d5d2bbd5ba7f7e", entries[0].Data["conn_trace_id"])
+	})
+
+	t.Run("Uncompressed CLB log", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		mockBody := `2024-03-21T16:10:26.071854Z my-clb 192.0.2.104:36217 10.0.0.24:80 0.000073 0.001048 0.000057 200 200 0 29 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.38.0" - -
+2024-03-21T16:10:27.071854Z my-clb 192.0.2.105:36218 10.0.0.24:443 0.000086 0.001048 0.001337 200 200 0 57 "GET https://www.example.com:443/ HTTP/1.1" "curl/7.38.0" DHE-RSA-AES128-SHA TLSv1.2
+`
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(strings.NewReader(mockBody)),
+		}, nil)
+
+		fields, err := NewFieldFilter(LBTypeCLB, "", FieldOptions{})
+		require.NoError(t, err)
+
+		lp := NewWithDeps(mockS3, &elbParser{fields: fields}, []destinations.Destination{mockDest})
+
+		err = lp.ProcessLogs(context.Background(), types.S3ObjectInfo{Bucket: "test-bucket", Key: "test-key.log"})
+		require.NoError(t, err)
+
+		entries := mockDest.Entries()
+		require.Len(t, entries, 2)
+		assert.Equal(t, time.Date(2024, 3, 21, 16, 10, 26, 71854000, time.UTC), entries[0].Timestamp)
+		assert.Equal(t, "my-clb", entries[0].Data["elb"])
+		assert.Equal(t, "10.0.0.24:80", entries[0].Data["backend:port"])
+		assert.Equal(t, "TLSv1.2", entries[1].Data["ssl_protocol"])
+	})
+
+	t.Run("Uncompressed object with gzip name", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		plain, err := os.ReadFile("testdata/sample.log")
+		require.NoError(t, err)
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(bytes.NewReader(plain)),
+		}, nil)
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+
+		err = lp.ProcessLogs(context.Background(), types.S3ObjectInfo{Bucket: "test-bucket", Key: "test-key.log.gz"})
+		require.NoError(t, err)
+		assert.Len(t, mockDest.Entries(), 5)
+	})
 }
 
 func TestParseRecords(t *testing.T) {
 	t.Run("Process CSV Records", func(t *testing.T) {
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
-		lp := &LogProcessor{fields: fields}
+		parser := &elbParser{fields: fields}
 
 		mockData := `https 2024-03-21T16:10:26.071854Z app/example-prod-lb/xxxxxxx4 192.0.2.104:36217 10.0.0.24:3003 0.004 0.024 0.003 203 203 1694 10783 "PUT https://example.com:443/api/modify?user_ids=xxxxx4-xxxx-xxxx-xxxx-xxxxxxxxxxxx&ref_date= HTTP/1.1" "axios/1.6.5" ECDHE-RSA-AES256-GCM-SHA384 TLSv1.3 arn:aws:elasticloadbalancing:xx-west-1:987654321098:targetgroup/example-prod-tg/xxxxxxxx4 "Root=1-xxxxxx4-xxxxxxxxxxxxxxxxxxxxxxxx" "example.com" "arn:aws:acm:xx-west-1:987654321098:certificate/aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa" 203 2024-03-21T16:10:26.061854Z "cache" "-" "-" "10.0.0.24:3003" "203" "-" "-" "TID_a1b2c3d4e5f67890abcdef1234567890"`
 
 		entryChan := make(chan types.LogEntry, 10)
 
 		go func() {
-			err := lp.parseRecords(strings.NewReader(mockData), entryChan)
+			stats, err := parser.Parse(strings.NewReader(mockData), entryChan, nil)
 			require.NoError(t, err)
+			assert.Equal(t, ParseStats{Type: LBTypeALB, Records: 1, Mismatched: 1}, stats)
 			close(entryChan)
 		}()
 
@@ -96,10 +202,10 @@ func TestParseRecords(t *testing.T) {
 
 func TestRecordToEntry(t *testing.T) {
 	t.Run("Valid Log Entry", func(t *testing.T) {
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
-		lp := &LogProcessor{fields: fields}
+		parser := &elbParser{fields: fields}
 
 		record := []string{
 			"https",
@@ -134,17 +240,17 @@ func TestRecordToEntry(t *testing.T) {
 			"TID_a1b2c3d4e5f67890abcdef1234567890",
 		}
 
-		logEntry, err := lp.recordToEntry(record)
+		logEntry, err := parser.recordToEntry(elbFields(record))
 		require.NoError(t, err)
 		assert.Equal(t, "2024-03-21T16:10:26.071854Z", logEntry.Timestamp.Format(time.RFC3339Nano))
 		assert.Equal(t, "PUT https://example.com:443/api/modify?user_ids=xxxxx4-xxxx-xxxx-xxxx-xxxxxxxxxxxx&ref_date= HTTP/1.1", logEntry.Data["request"])
 	})
 
-	t.Run("Extra fields are ignored for forward compatibility", func(t *testing.T) {
-		fields, err := NewFieldFilter(LBTypeALB, "")
+	t.Run("Extra fields are kept for forward compatibility", func(t *testing.T) {
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
-		lp := &LogProcessor{fields: fields}
+		parser := &elbParser{fields: fields}
 
 		// 33 known ALB fields + 3 extra future fields
 		record := []string{
@@ -187,23 +293,43 @@ func TestRecordToEntry(t *testing.T) {
 			"future_field_3",
 		}
 
-		logEntry, err := lp.recordToEntry(record)
+		logEntry, err := parser.recordToEntry(elbFields(record))
 		require.NoError(t, err)
 
 		// Should parse successfully
 		assert.Equal(t, "2024-03-21T16:10:26.071854Z", logEntry.Timestamp.Format(time.RFC3339Nano))
 		assert.Equal(t, "GET https://example.com:443/api/test HTTP/1.1", logEntry.Data["request"])
 
-		// Should only contain known fields (33 for ALB), not the extra ones
+		// Extra fields are named by their index
+		assert.Len(t, logEntry.Data, 36)
+		assert.Equal(t, "future_field_1", logEntry.Data["field_33"])
+		assert.Equal(t, "future_field_3", logEntry.Data["field_35"])
+	})
+
+	t.Run("Extra fields are dropped if configured", func(t *testing.T) {
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{ExtraFields: "drop"})
+		require.NoError(t, err)
+
+		parser := &elbParser{fields: fields}
+
+		record := make([]string, len(albFields)+1)
+		for i := range record {
+			record[i] = "-"
+		}
+		record[1] = "2024-03-21T16:10:26.071854Z"
+		record[len(albFields)] = "future_field_1"
+
+		logEntry, err := parser.recordToEntry(elbFields(record))
+		require.NoError(t, err)
 		assert.Len(t, logEntry.Data, 33)
-		assert.NotContains(t, logEntry.Data, "future_field_1")
+		assert.NotContains(t, logEntry.Data, "field_33")
 	})
 
 	t.Run("Fewer fields than expected works for backward compatibility", func(t *testing.T) {
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
-		lp := &LogProcessor{fields: fields}
+		parser := &elbParser{fields: fields}
 
 		// Only 10 fields instead of current 30 - simulates older log format
 		record := []string{
@@ -219,7 +345,7 @@ func TestRecordToEntry(t *testing.T) {
 			"203",
 		}
 
-		logEntry, err := lp.recordToEntry(record)
+		logEntry, err := parser.recordToEntry(elbFields(record))
 		require.NoError(t, err)
 
 		// Should parse timestamp correctly
@@ -237,30 +363,30 @@ func TestRecordToEntry(t *testing.T) {
 	})
 
 	t.Run("Too short record returns error", func(t *testing.T) {
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
-		lp := &LogProcessor{fields: fields}
+		parser := &elbParser{fields: fields}
 
 		// Only 1 field - can't even get timestamp (which is at index 1 for ALB)
 		record := []string{
 			"https",
 		}
 
-		_, err = lp.recordToEntry(record)
+		_, err = parser.recordToEntry(elbFields(record))
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "record too short")
 	})
 
 	t.Run("Empty record returns error", func(t *testing.T) {
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
-		lp := &LogProcessor{fields: fields}
+		parser := &elbParser{fields: fields}
 
 		record := []string{}
 
-		_, err = lp.recordToEntry(record)
+		_, err = parser.recordToEntry(elbFields(record))
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "record too short")
 	})
@@ -306,18 +432,21 @@ func loadTestData(t *testing.T) *bytes.Buffer {
 	return gzipData(t, data)
 }
 
-// MockDestination captures log entries for testing.
+// MockDestination captures log entries for testing. If err is set, it
+// reports that the entries could not be delivered.
 type MockDestination struct {
 	mu      sync.Mutex
 	entries []types.LogEntry
+	err     error
 }
 
-func (m *MockDestination) SendLogs(ctx context.Context, entries <-chan types.LogEntry) {
+func (m *MockDestination) SendLogs(ctx context.Context, entries <-chan types.LogEntry) error {
 	for entry := range entries {
 		m.mu.Lock()
 		m.entries = append(m.entries, entry)
 		m.mu.Unlock()
 	}
+	return m.err
 }
 
 func (m *MockDestination) Entries() []types.LogEntry {
@@ -336,12 +465,12 @@ func TestHandleLambdaEvent(t *testing.T) {
 			Body: io.NopCloser(buf),
 		}, nil)
 
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		lp := &LogProcessor{
 			s3:           mockS3,
-			fields:       fields,
+			parser:       &elbParser{fields: fields},
 			destinations: []destinations.Destination{mockDest},
 		}
 
@@ -381,12 +510,12 @@ func TestHandleLambdaEvent(t *testing.T) {
 			Body: io.NopCloser(loadTestData(t)),
 		}, nil).Once()
 
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		lp := &LogProcessor{
 			s3:           mockS3,
-			fields:       fields,
+			parser:       &elbParser{fields: fields},
 			destinations: []destinations.Destination{mockDest},
 		}
 
@@ -423,12 +552,12 @@ func TestHandleLambdaEvent(t *testing.T) {
 			fmt.Errorf("access denied"),
 		)
 
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		lp := &LogProcessor{
 			s3:           mockS3,
-			fields:       fields,
+			parser:       &elbParser{fields: fields},
 			destinations: []destinations.Destination{mockDest},
 		}
 
@@ -447,6 +576,78 @@ func TestHandleLambdaEvent(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "access denied")
 	})
+
+	t.Run("URL-encoded key is decoded", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		mockS3.On("GetObject", mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+			return *input.Key == "logs/my file.log.gz"
+		})).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+
+		event := events.S3Event{
+			Records: []events.S3EventRecord{
+				{
+					EventName: "ObjectCreated:Put",
+					S3: events.S3Entity{
+						Bucket: events.S3Bucket{Name: "test-bucket"},
+						Object: events.S3Object{Key: "logs/my+file.log.gz"},
+					},
+				},
+			},
+		}
+
+		err := lp.HandleLambdaEvent(context.Background(), event)
+		require.NoError(t, err)
+		assert.Len(t, mockDest.Entries(), 5)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("ELB test file is skipped", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{&MockDestination{}})
+
+		event := events.S3Event{
+			Records: []events.S3EventRecord{
+				{
+					EventName: "ObjectCreated:Put",
+					S3: events.S3Entity{
+						Bucket: events.S3Bucket{Name: "test-bucket"},
+						Object: events.S3Object{Key: "AWSLogs/123456789012/ELBAccessLogTestFile"},
+					},
+				},
+			},
+		}
+
+		err := lp.HandleLambdaEvent(context.Background(), event)
+		require.NoError(t, err)
+		mockS3.AssertNotCalled(t, "GetObject", mock.Anything)
+	})
+
+	t.Run("Dead letter object is skipped", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{&MockDestination{}})
+
+		event := events.S3Event{
+			Records: []events.S3EventRecord{
+				{
+					EventName: "ObjectCreated:Put",
+					S3: events.S3Entity{
+						Bucket: events.S3Bucket{Name: "test-bucket"},
+						Object: events.S3Object{Key: "test-bucket/AWSLogs/app.log.gz.rejected.jsonl"},
+					},
+				},
+			},
+		}
+
+		err := lp.HandleLambdaEvent(context.Background(), event)
+		require.NoError(t, err)
+		mockS3.AssertNotCalled(t, "GetObject", mock.Anything)
+	})
 }
 
 func TestHandleS3URL(t *testing.T) {
@@ -469,16 +670,16 @@ func TestHandleS3URL(t *testing.T) {
 			Body: io.NopCloser(loadTestData(t)),
 		}, nil).Once()
 
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		lp := &LogProcessor{
 			s3:           mockS3,
-			fields:       fields,
+			parser:       &elbParser{fields: fields},
 			destinations: []destinations.Destination{mockDest},
 		}
 
-		err = lp.HandleS3URL(context.Background(), "s3://my-bucket/logs/2024/03/21/")
+		err = lp.HandleS3URL(context.Background(), "s3://my-bucket/logs/2024/03/21/", BackfillOptions{})
 		require.NoError(t, err)
 
 		entries := mockDest.Entries()
@@ -517,16 +718,16 @@ func TestHandleS3URL(t *testing.T) {
 			Body: io.NopCloser(loadTestData(t)),
 		}, nil).Once()
 
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		lp := &LogProcessor{
 			s3:           mockS3,
-			fields:       fields,
+			parser:       &elbParser{fields: fields},
 			destinations: []destinations.Destination{mockDest},
 		}
 
-		err = lp.HandleS3URL(context.Background(), "s3://my-bucket/logs/")
+		err = lp.HandleS3URL(context.Background(), "s3://my-bucket/logs/", BackfillOptions{})
 		require.NoError(t, err)
 
 		entries := mockDest.Entries()
@@ -536,15 +737,15 @@ func TestHandleS3URL(t *testing.T) {
 
 	t.Run("Invalid S3 URL", func(t *testing.T) {
 		mockS3 := new(MockS3API)
-		fields, _ := NewFieldFilter(LBTypeALB, "")
+		fields, _ := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 
 		lp := &LogProcessor{
 			s3:           mockS3,
-			fields:       fields,
+			parser:       &elbParser{fields: fields},
 			destinations: []destinations.Destination{},
 		}
 
-		err := lp.HandleS3URL(context.Background(), "invalid-url")
+		err := lp.HandleS3URL(context.Background(), "invalid-url", BackfillOptions{})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "s3://")
 	})
@@ -560,12 +761,12 @@ func TestProcessLogsWithRealisticData(t *testing.T) {
 			Body: io.NopCloser(buf),
 		}, nil)
 
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		lp := &LogProcessor{
 			s3:           mockS3,
-			fields:       fields,
+			parser:       &elbParser{fields: fields},
 			destinations: []destinations.Destination{mockDest},
 		}
 
@@ -614,12 +815,12 @@ func TestProcessLogsWithRealisticData(t *testing.T) {
 			Body: io.NopCloser(buf),
 		}, nil)
 
-		fields, err := NewFieldFilter(LBTypeALB, "time,request,elb_status_code")
+		fields, err := NewFieldFilter(LBTypeALB, "time,request,elb_status_code", FieldOptions{})
 		require.NoError(t, err)
 
 		lp := &LogProcessor{
 			s3:           mockS3,
-			fields:       fields,
+			parser:       &elbParser{fields: fields},
 			destinations: []destinations.Destination{mockDest},
 		}
 
@@ -651,12 +852,12 @@ func TestProcessLogsWithRealisticData(t *testing.T) {
 			Body: io.NopCloser(buf),
 		}, nil)
 
-		fields, err := NewFieldFilter(LBTypeALB, "")
+		fields, err := NewFieldFilter(LBTypeALB, "", FieldOptions{})
 		require.NoError(t, err)
 
 		lp := &LogProcessor{
 			s3:           mockS3,
-			fields:       fields,
+			parser:       &elbParser{fields: fields},
 			destinations: []destinations.Destination{dest1, dest2},
 		}
 
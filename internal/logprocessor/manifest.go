@@ -0,0 +1,249 @@
+package logprocessor
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+var (
+	// bucketNameRe matches valid S3 bucket names.
+	bucketNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+	// csvHeaderRe matches the header row of query results with bucket and key
+	// columns, such as "bucket","key" from Athena.
+	csvHeaderRe = regexp.MustCompile(`(?i)^"?bucket(_name)?"?,`)
+)
+
+// inventoryManifest is the manifest.json of an S3 Inventory report.
+type inventoryManifest struct {
+	DestinationBucket string `json:"destinationBucket"`
+	FileFormat        string `json:"fileFormat"`
+	FileSchema        string `json:"fileSchema"`
+	Files             []struct {
+		Key string `json:"key"`
+	} `json:"files"`
+}
+
+// queueManifest reads the objects listed in a manifest and queues them on wp.
+// The manifest is a local path or S3 URL of a newline-delimited list of
+// s3://bucket/key URLs (also as single-column CSV), an S3 Inventory CSV file
+// (.csv or .csv.gz), or an S3 Inventory manifest.json. Manifests may be gzipped.
+func (p *LogProcessor) queueManifest(wp *workPool, location string, opts BackfillOptions) {
+	cp := opts.Checkpoint
+
+	wp.list(func(ctx context.Context) error {
+		return p.readManifest(ctx, location, func(obj types.S3ObjectInfo) {
+			id := objectID(obj.Bucket, obj.Key)
+			if !opts.matches(obj.Key) || cp.isCompleted(id) {
+				return
+			}
+			wp.work(func(ctx context.Context) error {
+				if err := p.processObject(ctx, obj); err != nil {
+					return err
+				}
+				cp.markCompleted(id)
+				return nil
+			})
+		})
+	})
+}
+
+// readManifest calls fn for every object listed in a manifest.
+func (p *LogProcessor) readManifest(ctx context.Context, location string, fn func(types.S3ObjectInfo)) error {
+	r, err := p.openManifest(location)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	name := strings.TrimSuffix(location, ".gz")
+	switch {
+	case strings.HasSuffix(name, ".json"):
+		err = p.readInventoryManifest(ctx, r, fn)
+	case strings.HasSuffix(name, ".csv"):
+		// Single-column CSV, such as Athena query results, is a list of URLs
+		br := bufio.NewReader(r)
+		first, rerr := br.ReadString('\n')
+		r := io.MultiReader(strings.NewReader(first), br)
+		switch {
+		case rerr != nil && rerr != io.EOF:
+			err = fmt.Errorf("read: %w", rerr)
+		case strings.Contains(first, ","):
+			if csvHeaderRe.MatchString(first) {
+				r = br
+			}
+			err = readInventoryCSV(ctx, r, 0, 1, fn)
+		default:
+			err = readObjectList(ctx, r, fn)
+		}
+	default:
+		err = readObjectList(ctx, r, fn)
+	}
+	if err != nil {
+		return fmt.Errorf("manifest %s: %w", location, err)
+	}
+	return nil
+}
+
+// openManifest opens a local or S3 manifest, decompressing it if gzipped.
+func (p *LogProcessor) openManifest(location string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	if strings.HasPrefix(location, "s3://") {
+		bucket, key, err := parseS3URL(location)
+		if err != nil {
+			return nil, fmt.Errorf("parse S3 URL: %w", err)
+		}
+		resp, err := p.s3.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get manifest %s: %w", location, err)
+		}
+		rc = resp.Body
+	} else {
+		f, err := os.Open(strings.TrimPrefix(location, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("open manifest: %w", err)
+		}
+		rc = f
+	}
+
+	r, err := maybeGunzip(rc)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("manifest %s: %w", location, err)
+	}
+	return manifestReader{r, rc}, nil
+}
+
+// manifestReader reads a decompressed manifest and closes it with its source.
+type manifestReader struct {
+	io.ReadCloser
+	source io.Closer
+}
+
+func (m manifestReader) Close() error {
+	m.ReadCloser.Close()
+	return m.source.Close()
+}
+
+// readObjectList reads one s3://bucket/key URL per line. Blank lines and
+// # comments are skipped, values may be quoted, and a header line (as in
+// Athena query results) is skipped.
+func readObjectList(ctx context.Context, r io.Reader, fn func(types.S3ObjectInfo)) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		v := strings.Trim(strings.TrimSpace(scanner.Text()), `"`)
+		if v == "" || strings.HasPrefix(v, "#") {
+			continue
+		}
+		if line == 1 && !strings.HasPrefix(v, "s3://") {
+			continue
+		}
+
+		bucket, key, err := parseS3URL(v)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if key == "" || strings.HasSuffix(key, "/") {
+			return fmt.Errorf("line %d: %q is not an object URL", line, v)
+		}
+		fn(types.S3ObjectInfo{Bucket: bucket, Key: key})
+	}
+	return scanner.Err()
+}
+
+// readInventoryCSV reads an S3 Inventory CSV file, whose object keys are
+// URL-encoded, with the bucket and key in the given columns.
+func readInventoryCSV(ctx context.Context, r io.Reader, bucketCol, keyCol int, fn func(types.S3ObjectInfo)) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read CSV: %w", err)
+		}
+		if len(record) <= max(bucketCol, keyCol) {
+			line, _ := cr.FieldPos(0)
+			return fmt.Errorf("line %d: expected at least %d columns", line, max(bucketCol, keyCol)+1)
+		}
+
+		if !bucketNameRe.MatchString(record[bucketCol]) {
+			line, _ := cr.FieldPos(bucketCol)
+			return fmt.Errorf("line %d: %q is not a bucket name", line, record[bucketCol])
+		}
+
+		key, err := url.QueryUnescape(record[keyCol])
+		if err != nil {
+			return fmt.Errorf("decode object key %q: %w", record[keyCol], err)
+		}
+		fn(types.S3ObjectInfo{Bucket: record[bucketCol], Key: key})
+	}
+}
+
+// readInventoryManifest reads the CSV files listed in an S3 Inventory manifest.json.
+func (p *LogProcessor) readInventoryManifest(ctx context.Context, r io.Reader, fn func(types.S3ObjectInfo)) error {
+	var m inventoryManifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return fmt.Errorf("decode manifest.json: %w", err)
+	}
+	if m.FileFormat != "CSV" {
+		return fmt.Errorf("unsupported inventory format %q (only CSV is supported)", m.FileFormat)
+	}
+
+	bucketCol, keyCol := -1, -1
+	for i, col := range strings.Split(m.FileSchema, ",") {
+		switch strings.TrimSpace(col) {
+		case "Bucket":
+			bucketCol = i
+		case "Key":
+			keyCol = i
+		}
+	}
+	if bucketCol < 0 || keyCol < 0 {
+		return fmt.Errorf("inventory schema %q has no Bucket and Key columns", m.FileSchema)
+	}
+
+	// The destination bucket is given as an ARN (arn:aws:s3:::bucket)
+	bucket := m.DestinationBucket[strings.LastIndex(m.DestinationBucket, ":")+1:]
+
+	for _, file := range m.Files {
+		location := "s3://" + bucket + "/" + file.Key
+		rc, err := p.openManifest(location)
+		if err != nil {
+			return err
+		}
+		err = readInventoryCSV(ctx, rc, bucketCol, keyCol, fn)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", location, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package logprocessor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func collectObjects(objs *[]types.S3ObjectInfo) func(types.S3ObjectInfo) {
+	return func(obj types.S3ObjectInfo) {
+		*objs = append(*objs, obj)
+	}
+}
+
+func TestReadObjectList(t *testing.T) {
+	t.Run("URLs with header, quotes and comments", func(t *testing.T) {
+		list := "\"path\"\n\"s3://logs/a.log.gz\"\n\n# skipped\ns3://other/dir/b.log.gz\n"
+
+		var objs []types.S3ObjectInfo
+		require.NoError(t, readObjectList(context.Background(), strings.NewReader(list), collectObjects(&objs)))
+		assert.Equal(t, []types.S3ObjectInfo{
+			{Bucket: "logs", Key: "a.log.gz"},
+			{Bucket: "other", Key: "dir/b.log.gz"},
+		}, objs)
+	})
+
+	t.Run("Invalid line", func(t *testing.T) {
+		err := readObjectList(context.Background(), strings.NewReader("s3://logs/a.log.gz\nlogs/b.log.gz\n"), func(types.S3ObjectInfo) {})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "line 2")
+	})
+
+	t.Run("Prefix is not an object", func(t *testing.T) {
+		err := readObjectList(context.Background(), strings.NewReader("s3://logs/dir/\n"), func(types.S3ObjectInfo) {})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not an object URL")
+	})
+}
+
+func TestReadInventoryCSV(t *testing.T) {
+	csv := "\"logs\",\"AWSLogs/a%20b.log.gz\",\"1024\"\n\"logs\",\"AWSLogs/c.log.gz\",\"2048\"\n"
+
+	var objs []types.S3ObjectInfo
+	require.NoError(t, readInventoryCSV(context.Background(), strings.NewReader(csv), 0, 1, collectObjects(&objs)))
+	assert.Equal(t, []types.S3ObjectInfo{
+		{Bucket: "logs", Key: "AWSLogs/a b.log.gz"},
+		{Bucket: "logs", Key: "AWSLogs/c.log.gz"},
+	}, objs)
+
+	err := readInventoryCSV(context.Background(), strings.NewReader("\"logs\"\n"), 0, 1, func(types.S3ObjectInfo) {})
+	require.Error(t, err)
+
+	err = readInventoryCSV(context.Background(), strings.NewReader("\"Bucket Name\",\"Key\"\n"), 0, 1, func(types.S3ObjectInfo) {})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 1: \"Bucket Name\" is not a bucket name")
+}
+
+func TestHandleInputs(t *testing.T) {
+	t.Run("S3 URLs, local files and an inventory manifest", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		manifest := `{
+			"sourceBucket": "logs",
+			"destinationBucket": "arn:aws:s3:::inventory",
+			"fileFormat": "CSV",
+			"fileSchema": "Bucket, Key, Size",
+			"files": [{"key": "logs/daily/data/abc.csv.gz"}]
+		}`
+		mockS3.On("GetObject", mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+			return *input.Bucket == "inventory" && *input.Key == "logs/daily/manifest.json"
+		})).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(strings.NewReader(manifest)),
+		}, nil).Once()
+		mockS3.On("GetObject", mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+			return *input.Bucket == "inventory" && *input.Key == "logs/daily/data/abc.csv.gz"
+		})).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(gzipData(t, []byte("\"logs\",\"inventory/one.log.gz\",\"10\"\n"))),
+		}, nil).Once()
+
+		for _, prefix := range []string{"alb/", "nlb/"} {
+			mockS3.On("ListObjectsV2", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+				return *input.Prefix == prefix
+			})).Return(&s3.ListObjectsV2Output{
+				Contents:    []*s3.Object{{Key: aws.String(prefix + "file.log.gz")}},
+				IsTruncated: aws.Bool(false),
+			}, nil).Once()
+		}
+
+		for _, key := range []string{"inventory/one.log.gz", "alb/file.log.gz", "nlb/file.log.gz"} {
+			mockS3.On("GetObject", keyIs(key)).Return(&s3.GetObjectOutput{
+				Body: io.NopCloser(loadTestData(t)),
+			}, nil).Once()
+		}
+
+		dir := writeLogFiles(t)
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+
+		err := lp.HandleInputs(context.Background(),
+			[]string{"s3://logs/alb/", "s3://logs/nlb/", dir},
+			[]string{"s3://inventory/logs/daily/manifest.json"},
+			BackfillOptions{})
+		require.NoError(t, err)
+
+		assert.Len(t, mockDest.Entries(), 25)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Local object list", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		mockS3.On("GetObject", keyIs("a.log.gz")).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+
+		path := filepath.Join(t.TempDir(), "objects.txt")
+		require.NoError(t, os.WriteFile(path, []byte("s3://logs/a.log.gz\n"), 0o644))
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+		err := lp.HandleInputs(context.Background(), nil, []string{path}, BackfillOptions{})
+		require.NoError(t, err)
+
+		assert.Len(t, mockDest.Entries(), 5)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Single-column CSV is an object list", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		mockS3.On("GetObject", keyIs("a.log.gz")).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+
+		path := filepath.Join(t.TempDir(), "athena-results.csv")
+		require.NoError(t, os.WriteFile(path, []byte("\"path\"\n\"s3://logs/a.log.gz\"\n"), 0o644))
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+		err := lp.HandleInputs(context.Background(), nil, []string{path}, BackfillOptions{})
+		require.NoError(t, err)
+
+		assert.Len(t, mockDest.Entries(), 5)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Header row of a multi-column CSV is skipped", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		mockS3.On("GetObject", keyIs("a.log.gz")).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+
+		path := filepath.Join(t.TempDir(), "athena-results.csv")
+		require.NoError(t, os.WriteFile(path, []byte("\"bucket\",\"key\"\n\"logs\",\"a.log.gz\"\n"), 0o644))
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+		err := lp.HandleInputs(context.Background(), nil, []string{path}, BackfillOptions{})
+		require.NoError(t, err)
+
+		assert.Len(t, mockDest.Entries(), 5)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Truncated CSV is an error", func(t *testing.T) {
+		gz := gzipData(t, []byte(`"s3://logs/a.log.gz"`)).Bytes()
+		path := filepath.Join(t.TempDir(), "athena-results.csv.gz")
+		require.NoError(t, os.WriteFile(path, gz[:len(gz)-4], 0o644))
+
+		lp := NewWithDeps(new(MockS3API), nil, []destinations.Destination{&MockDestination{}})
+		err := lp.HandleInputs(context.Background(), nil, []string{path}, BackfillOptions{})
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("Unsupported inventory format", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"fileFormat":"Parquet"}`), 0o644))
+
+		lp := NewWithDeps(new(MockS3API), nil, []destinations.Destination{&MockDestination{}})
+		err := lp.HandleInputs(context.Background(), nil, []string{path}, BackfillOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "only CSV is supported")
+	})
+
+	t.Run("Invalid input stops the run", func(t *testing.T) {
+		lp := NewWithDeps(new(MockS3API), nil, []destinations.Destination{&MockDestination{}})
+		err := lp.HandleInputs(context.Background(), []string{writeLogFiles(t), "s3://"}, nil, BackfillOptions{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,69 @@
+package logprocessor
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Metrics publishes counters as CloudWatch metrics in the embedded metric
+// format: JSON lines written to stderr, from which CloudWatch Logs extracts
+// the metrics of a Lambda function without API calls. Stdout is left to the
+// stdout destination and dead letter sink. A nil *Metrics discards them.
+type Metrics struct {
+	namespace string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// metricsFromEnv returns the Metrics for METRICS_NAMESPACE, or nil if unset.
+func metricsFromEnv() *Metrics {
+	ns := os.Getenv("METRICS_NAMESPACE")
+	if ns == "" {
+		return nil
+	}
+	return &Metrics{namespace: ns, w: os.Stderr}
+}
+
+// count publishes value for the named counter with the given dimensions.
+func (m *Metrics) count(name string, value int, dims map[string]string) {
+	if m == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(dims))
+	for k := range dims {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	doc := map[string]any{
+		"_aws": map[string]any{
+			"Timestamp": time.Now().UnixMilli(),
+			"CloudWatchMetrics": []map[string]any{{
+				"Namespace":  m.namespace,
+				"Dimensions": [][]string{keys},
+				"Metrics":    []map[string]string{{"Name": name, "Unit": "Count"}},
+			}},
+		},
+		name: value,
+	}
+	for k, v := range dims {
+		doc[k] = v
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		slog.Error("marshal metric failed", "name", name, "error", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.w.Write(append(data, '\n'))
+}
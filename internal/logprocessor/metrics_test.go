@@ -0,0 +1,70 @@
+package logprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("Embedded metric format", func(t *testing.T) {
+		var buf bytes.Buffer
+		m := &Metrics{namespace: "LogForwarder", w: &buf}
+
+		m.count("FieldCountMismatch", 3, map[string]string{"LogType": "alb"})
+
+		var doc struct {
+			AWS struct {
+				CloudWatchMetrics []struct {
+					Namespace  string
+					Dimensions [][]string
+					Metrics    []struct{ Name, Unit string }
+				}
+			} `json:"_aws"`
+			LogType            string
+			FieldCountMismatch int
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+		require.Len(t, doc.AWS.CloudWatchMetrics, 1)
+		assert.Equal(t, "LogForwarder", doc.AWS.CloudWatchMetrics[0].Namespace)
+		assert.Equal(t, [][]string{{"LogType"}}, doc.AWS.CloudWatchMetrics[0].Dimensions)
+		assert.Equal(t, "FieldCountMismatch", doc.AWS.CloudWatchMetrics[0].Metrics[0].Name)
+		assert.Equal(t, "alb", doc.LogType)
+		assert.Equal(t, 3, doc.FieldCountMismatch)
+	})
+
+	t.Run("Disabled without namespace", func(t *testing.T) {
+		t.Setenv("METRICS_NAMESPACE", "")
+		m := metricsFromEnv()
+		assert.Nil(t, m)
+		m.count("FieldCountMismatch", 1, nil)
+	})
+
+	t.Run("Field count mismatch while processing", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(strings.NewReader(clbRecord + " extra\n" + clbRecord + "\n")),
+		}, nil)
+
+		parser, err := NewParser(LBTypeCLB, "", FieldOptions{})
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		lp := NewWithDeps(mockS3, parser, []destinations.Destination{&MockDestination{}})
+		lp.metrics = &Metrics{namespace: "LogForwarder", w: &buf}
+
+		require.NoError(t, lp.ProcessLogs(context.Background(), types.S3ObjectInfo{Bucket: "logs", Key: "clb.log"}))
+		assert.Contains(t, buf.String(), `"FieldCountMismatch":1`)
+		assert.Contains(t, buf.String(), `"LogType":"clb"`)
+	})
+}
@@ -0,0 +1,119 @@
+package logprocessor
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// Parser parses the records of a log file into entries.
+type Parser interface {
+	// Parse sends an entry for every record read from r to out. Records that
+	// cannot be parsed are passed to reject, and parsing stops if it returns
+	// an error. Parse returns an error if r cannot be parsed at all.
+	Parse(r io.Reader, out chan<- types.LogEntry, reject RejectFunc) (ParseStats, error)
+}
+
+// RejectFunc handles a record that cannot be parsed, given its 1-based line
+// number, the raw line and the reason. A nil RejectFunc fails on any record.
+type RejectFunc func(line int, raw string, err error) error
+
+// ParseStats describes the records parsed from a log file.
+type ParseStats struct {
+	Type    LBType
+	Records int
+	// Mismatched counts the records whose number of fields differs from the
+	// known fields, which means AWS changed the format.
+	Mismatched int
+	// Rejected counts the records that could not be parsed.
+	Rejected int
+}
+
+// reject passes a record that cannot be parsed to fn and counts it.
+func (s *ParseStats) reject(fn RejectFunc, line int, raw string, err error) error {
+	if fn == nil {
+		return fmt.Errorf("line %d: %w", line, err)
+	}
+	if err := fn(line, raw, err); err != nil {
+		return err
+	}
+	s.Rejected++
+	return nil
+}
+
+// count adds a parsed record with n fields when expected are known.
+func (s *ParseStats) count(n, expected int) {
+	s.Records++
+	if n != expected {
+		s.Mismatched++
+	}
+}
+
+// ParserFactory creates a Parser including the fields in fieldConfig, a
+// comma-separated list of field names (all fields if empty), configured by opts.
+type ParserFactory func(fieldConfig string, opts FieldOptions) (Parser, error)
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = map[LBType]ParserFactory{
+		LBTypeALB:        elbParserFactory(LBTypeALB),
+		LBTypeNLB:        elbParserFactory(LBTypeNLB),
+		LBTypeCLB:        elbParserFactory(LBTypeCLB),
+		LBTypeALBConn:    elbParserFactory(LBTypeALBConn),
+		LBTypeAuto:       newAutoParser,
+		LBTypeCloudFront: newCloudFrontParser,
+		LBTypeS3Access:   newS3AccessParser,
+		LBTypeVPCFlow:    newVPCFlowParser,
+	}
+)
+
+// RegisterParser makes a log type available by name, as selected by LB_TYPE.
+// Registering a name twice replaces the earlier factory.
+func RegisterParser(name LBType, factory ParserFactory) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[name] = factory
+}
+
+// NewParser creates a Parser for the log type registered as name.
+func NewParser(name LBType, fieldConfig string, opts FieldOptions) (Parser, error) {
+	parsersMu.RLock()
+	factory, ok := parsers[name]
+	parsersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("invalid log type: %q (use %s)", name, strings.Join(parserNames(), ", "))
+	}
+	return factory(fieldConfig, opts)
+}
+
+// parserNames returns the registered log types in sorted order.
+func parserNames() []string {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	names := make([]string, 0, len(parsers))
+	for name := range parsers {
+		names = append(names, "'"+string(name)+"'")
+	}
+	sort.Strings(names)
+	return names
+}
+
+// namedData returns the included fields of a record whose field names are
+// given by names, such as the header of a CloudFront or VPC Flow Logs file.
+func namedData(fields *FieldFilter, names, record []string) map[string]any {
+	data := make(map[string]any)
+	for i, val := range record {
+		if i >= len(names) {
+			if name, ok := fields.extraName(i); ok {
+				data[name] = fields.value(name, val)
+			}
+		} else if fields.IncludesName(names[i]) {
+			data[names[i]] = fields.value(names[i], val)
+		}
+	}
+	return data
+}
@@ -0,0 +1,78 @@
+package logprocessor
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseAll runs parser on input and returns the entries it produced.
+func parseAll(t *testing.T, parser Parser, input string) ([]types.LogEntry, error) {
+	t.Helper()
+	out := make(chan types.LogEntry, 100)
+	_, err := parser.Parse(strings.NewReader(input), out, nil)
+	close(out)
+
+	var entries []types.LogEntry
+	for entry := range out {
+		entries = append(entries, entry)
+	}
+	return entries, err
+}
+
+type lineParser struct{}
+
+func (lineParser) Parse(r io.Reader, out chan<- types.LogEntry, reject RejectFunc) (ParseStats, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ParseStats{}, err
+	}
+	stats := ParseStats{Type: "lines"}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		stats.count(1, 1)
+		out <- types.LogEntry{Data: map[string]any{"line": line}}
+	}
+	return stats, nil
+}
+
+func TestNewParser(t *testing.T) {
+	t.Run("Built-in log types", func(t *testing.T) {
+		for _, name := range []LBType{LBTypeALB, LBTypeNLB, LBTypeCLB, LBTypeALBConn, LBTypeAuto, LBTypeCloudFront, LBTypeS3Access, LBTypeVPCFlow} {
+			parser, err := NewParser(name, "", FieldOptions{})
+			require.NoError(t, err, name)
+			assert.NotNil(t, parser, name)
+		}
+	})
+
+	t.Run("Fields are validated", func(t *testing.T) {
+		_, err := NewParser(LBTypeCloudFront, "date,nonexistent", FieldOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid field name")
+	})
+
+	t.Run("Unknown log type", func(t *testing.T) {
+		_, err := NewParser("waf", "", FieldOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid log type")
+		assert.Contains(t, err.Error(), "'vpc_flow'")
+	})
+
+	t.Run("Registered parser", func(t *testing.T) {
+		RegisterParser("lines", func(string, FieldOptions) (Parser, error) { return lineParser{}, nil })
+		t.Cleanup(func() {
+			parsersMu.Lock()
+			delete(parsers, "lines")
+			parsersMu.Unlock()
+		})
+
+		parser, err := NewParser("lines", "", FieldOptions{})
+		require.NoError(t, err)
+		entries, err := parseAll(t, parser, "a\nb\n")
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+}
@@ -0,0 +1,237 @@
+package logprocessor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const (
+	defaultPollerConcurrency       = 10
+	defaultPollerVisibilityTimeout = 5 * time.Minute
+
+	// maxReceiveMessages is the SQS limit on messages per receive.
+	maxReceiveMessages = 10
+	// receiveWaitSeconds is the long poll duration, the SQS maximum.
+	receiveWaitSeconds = 20
+	receiveRetryDelay  = 5 * time.Second
+)
+
+// SQSAPI defines the SQS operations used by Poller. Receives take a context
+// so a long poll is interrupted on shutdown.
+type SQSAPI interface {
+	ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error)
+	ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error)
+	DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error)
+}
+
+// Poller long-polls an SQS queue of S3 notifications and processes the
+// referenced objects. It is the long-running alternative to the Lambda
+// SQS trigger.
+type Poller struct {
+	sqs               SQSAPI
+	proc              *LogProcessor
+	queueURL          string
+	concurrency       int
+	visibilityTimeout time.Duration
+	heartbeat         time.Duration
+}
+
+// NewPoller creates a Poller from environment configuration.
+func NewPoller(sess *session.Session, proc *LogProcessor) (*Poller, error) {
+	queueURL := os.Getenv("SQS_QUEUE_URL")
+	if queueURL == "" {
+		return nil, fmt.Errorf("SQS_QUEUE_URL is required")
+	}
+
+	concurrency := defaultPollerConcurrency
+	if v := os.Getenv("SQS_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid SQS_CONCURRENCY: %q", v)
+		}
+		concurrency = n
+	}
+
+	visibilityTimeout := defaultPollerVisibilityTimeout
+	if v := os.Getenv("SQS_VISIBILITY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SQS_VISIBILITY_TIMEOUT: %w", err)
+		}
+		if d < 2*time.Second || d > 12*time.Hour {
+			return nil, fmt.Errorf("invalid SQS_VISIBILITY_TIMEOUT: must be between 2s and 12h")
+		}
+		visibilityTimeout = d
+	}
+
+	return NewPollerWithDeps(sqs.New(sess), proc, queueURL, concurrency, visibilityTimeout), nil
+}
+
+// NewPollerWithDeps creates a Poller with explicit dependencies (for testing).
+func NewPollerWithDeps(client SQSAPI, proc *LogProcessor, queueURL string, concurrency int, visibilityTimeout time.Duration) *Poller {
+	return &Poller{
+		sqs:               client,
+		proc:              proc,
+		queueURL:          queueURL,
+		concurrency:       concurrency,
+		visibilityTimeout: visibilityTimeout,
+		heartbeat:         visibilityTimeout / 2,
+	}
+}
+
+// Run polls the queue until ctx is cancelled. It then stops receiving and
+// waits for in-flight messages to finish; messages that are not deleted
+// become visible again once their visibility timeout expires.
+func (p *Poller) Run(ctx context.Context) error {
+	slog.Info("polling SQS queue", "queue_url", p.queueURL, "concurrency", p.concurrency)
+
+	// In-flight messages are finished after shutdown starts, so they are not
+	// processed twice
+	procCtx := context.WithoutCancel(ctx)
+
+	slots := make(chan struct{}, p.concurrency)
+	var wg sync.WaitGroup
+
+	for {
+		n := acquireSlots(ctx, slots)
+		if n == 0 {
+			break
+		}
+
+		msgs, err := p.receive(ctx, n)
+		for range n - len(msgs) {
+			<-slots
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			slog.Error("receive failed", "queue_url", p.queueURL, "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(receiveRetryDelay):
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				p.handleMessage(procCtx, msg)
+			}()
+		}
+	}
+
+	slog.Info("draining in-flight messages")
+	wg.Wait()
+	return nil
+}
+
+// acquireSlots blocks until at least one processing slot is free and takes up
+// to maxReceiveMessages free slots. It returns 0 when ctx is cancelled.
+func acquireSlots(ctx context.Context, slots chan struct{}) int {
+	select {
+	case <-ctx.Done():
+		return 0
+	case slots <- struct{}{}:
+	}
+
+	n := 1
+	for n < maxReceiveMessages {
+		select {
+		case slots <- struct{}{}:
+			n++
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+func (p *Poller) receive(ctx context.Context, n int) ([]*sqs.Message, error) {
+	resp, err := p.sqs.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(p.queueURL),
+		MaxNumberOfMessages: aws.Int64(int64(n)),
+		WaitTimeSeconds:     aws.Int64(receiveWaitSeconds),
+		VisibilityTimeout:   aws.Int64(int64(p.visibilityTimeout / time.Second)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Messages, nil
+}
+
+// handleMessage processes the objects of one message and deletes it once all
+// destinations accepted them. Failed messages are left for redelivery.
+func (p *Poller) handleMessage(ctx context.Context, msg *sqs.Message) {
+	id := aws.StringValue(msg.MessageId)
+
+	stop := p.extendVisibility(msg)
+	defer stop()
+
+	objs, err := notificationObjects([]byte(aws.StringValue(msg.Body)))
+	if err != nil {
+		slog.Error("invalid SQS message body", "message_id", id, "error", err)
+		return
+	}
+
+	if err := p.proc.processObjects(ctx, objs); err != nil {
+		slog.Error("message failed, leaving it for redelivery", "message_id", id, "error", err)
+		return
+	}
+
+	_, err = p.sqs.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(p.queueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		slog.Error("delete message failed", "message_id", id, "error", err)
+	}
+}
+
+// extendVisibility keeps msg invisible while it is processed by resetting its
+// visibility timeout every heartbeat. The returned function stops it.
+func (p *Poller) extendVisibility(msg *sqs.Message) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(p.heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, err := p.sqs.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+					QueueUrl:          aws.String(p.queueURL),
+					ReceiptHandle:     msg.ReceiptHandle,
+					VisibilityTimeout: aws.Int64(int64(p.visibilityTimeout / time.Second)),
+				})
+				if err != nil {
+					slog.Warn("extend visibility failed", "message_id", aws.StringValue(msg.MessageId), "error", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
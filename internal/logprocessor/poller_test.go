@@ -0,0 +1,171 @@
+package logprocessor
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockSQSAPI struct {
+	mock.Mock
+}
+
+func (m *MockSQSAPI) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	args := m.Called(ctx, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sqs.ReceiveMessageOutput), args.Error(1)
+}
+
+func (m *MockSQSAPI) ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*sqs.ChangeMessageVisibilityOutput), args.Error(1)
+}
+
+func (m *MockSQSAPI) DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*sqs.DeleteMessageOutput), args.Error(1)
+}
+
+// blockUntilCancelled makes further receives long-poll until shutdown.
+func blockUntilCancelled(m *MockSQSAPI) {
+	m.On("ReceiveMessageWithContext", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(nil, context.Canceled).Maybe()
+}
+
+func receiptIs(handle string) any {
+	return mock.MatchedBy(func(input *sqs.DeleteMessageInput) bool {
+		return *input.ReceiptHandle == handle
+	})
+}
+
+func TestPoller(t *testing.T) {
+	t.Run("Deletes only messages that succeeded", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockSQS := new(MockSQSAPI)
+		mockDest := &MockDestination{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mockS3.On("GetObject", keyIs("logs/ok.log.gz")).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+		mockS3.On("GetObject", keyIs("logs/bad.log.gz")).Return((*s3.GetObjectOutput)(nil), assert.AnError).Once()
+
+		mockSQS.On("ReceiveMessageWithContext", mock.Anything, mock.MatchedBy(func(input *sqs.ReceiveMessageInput) bool {
+			return *input.MaxNumberOfMessages == 4 && *input.VisibilityTimeout == 60
+		})).Return(&sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{
+				{MessageId: aws.String("msg-1"), ReceiptHandle: aws.String("r-1"), Body: aws.String(s3NotificationBody("test-bucket", "logs/ok.log.gz"))},
+				{MessageId: aws.String("msg-2"), ReceiptHandle: aws.String("r-2"), Body: aws.String(s3NotificationBody("test-bucket", "logs/bad.log.gz"))},
+				{MessageId: aws.String("msg-3"), ReceiptHandle: aws.String("r-3"), Body: aws.String("not json")},
+			},
+		}, nil).Once()
+		blockUntilCancelled(mockSQS)
+		mockSQS.On("DeleteMessage", receiptIs("r-1")).Run(func(mock.Arguments) {
+			cancel()
+		}).Return(&sqs.DeleteMessageOutput{}, nil).Once()
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+		poller := NewPollerWithDeps(mockSQS, lp, "queue", 4, time.Minute)
+
+		require.NoError(t, poller.Run(ctx))
+
+		assert.Len(t, mockDest.Entries(), 5)
+		mockS3.AssertExpectations(t)
+		mockSQS.AssertExpectations(t)
+		mockSQS.AssertNumberOfCalls(t, "DeleteMessage", 1)
+	})
+
+	t.Run("In-flight message is finished on shutdown and kept invisible", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockSQS := new(MockSQSAPI)
+		mockDest := &MockDestination{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mockSQS.On("ReceiveMessageWithContext", mock.Anything, mock.Anything).Return(&sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{
+				{MessageId: aws.String("msg-1"), ReceiptHandle: aws.String("r-1"), Body: aws.String(s3NotificationBody("test-bucket", "logs/slow.log.gz"))},
+			},
+		}, nil).Once()
+		blockUntilCancelled(mockSQS)
+
+		// Shut down while the object is being downloaded
+		mockS3.On("GetObject", keyIs("logs/slow.log.gz")).Run(func(mock.Arguments) {
+			cancel()
+		}).After(100*time.Millisecond).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+
+		mockSQS.On("ChangeMessageVisibility", mock.MatchedBy(func(input *sqs.ChangeMessageVisibilityInput) bool {
+			return *input.ReceiptHandle == "r-1" && *input.VisibilityTimeout == 60
+		})).Return(&sqs.ChangeMessageVisibilityOutput{}, nil)
+		mockSQS.On("DeleteMessage", receiptIs("r-1")).Return(&sqs.DeleteMessageOutput{}, nil).Once()
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+		poller := NewPollerWithDeps(mockSQS, lp, "queue", 1, time.Minute)
+		poller.heartbeat = 10 * time.Millisecond
+
+		require.NoError(t, poller.Run(ctx))
+
+		assert.Len(t, mockDest.Entries(), 5)
+		mockS3.AssertExpectations(t)
+		mockSQS.AssertExpectations(t)
+	})
+
+	t.Run("Message is kept when a destination fails", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockSQS := new(MockSQSAPI)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mockSQS.On("ReceiveMessageWithContext", mock.Anything, mock.Anything).Return(&sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{
+				{MessageId: aws.String("msg-1"), ReceiptHandle: aws.String("r-1"), Body: aws.String(s3NotificationBody("test-bucket", "logs/ok.log.gz"))},
+			},
+		}, nil).Once()
+		blockUntilCancelled(mockSQS)
+
+		// Shut down once the message is in progress; it is still finished
+		mockS3.On("GetObject", keyIs("logs/ok.log.gz")).Run(func(mock.Arguments) {
+			cancel()
+		}).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+
+		failing := &MockDestination{err: assert.AnError}
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{&MockDestination{}, failing})
+		poller := NewPollerWithDeps(mockSQS, lp, "queue", 1, time.Minute)
+
+		require.NoError(t, poller.Run(ctx))
+
+		assert.Len(t, failing.Entries(), 5)
+		mockS3.AssertExpectations(t)
+		mockSQS.AssertNotCalled(t, "DeleteMessage", mock.Anything)
+	})
+}
+
+func TestAcquireSlots(t *testing.T) {
+	slots := make(chan struct{}, 3)
+	assert.Equal(t, 3, acquireSlots(context.Background(), slots))
+
+	<-slots
+	assert.Equal(t, 1, acquireSlots(context.Background(), slots))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, 0, acquireSlots(ctx, slots))
+}
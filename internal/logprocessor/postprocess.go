@@ -0,0 +1,189 @@
+package logprocessor
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// Actions applied to an object once it has been fully forwarded.
+const (
+	PostActionNone   = ""
+	PostActionTag    = "tag"
+	PostActionCopy   = "copy"
+	PostActionMove   = "move"
+	PostActionDelete = "delete"
+)
+
+// Tags set by the tag action.
+const (
+	forwardedTag   = "forwarded"
+	forwardedAtTag = "forwarded-at"
+)
+
+// PostProcess configures the action applied to an object after forwarding.
+type PostProcess struct {
+	Action string
+
+	// ArchiveBucket and ArchivePrefix locate the copy made by the copy and
+	// move actions. The bucket defaults to the source bucket.
+	ArchiveBucket string
+	ArchivePrefix string
+}
+
+// postProcessFromEnv reads the post-processing configuration from the environment.
+func postProcessFromEnv() (PostProcess, error) {
+	pp := PostProcess{
+		Action:        strings.ToLower(os.Getenv("POST_PROCESS")),
+		ArchiveBucket: os.Getenv("ARCHIVE_BUCKET"),
+		ArchivePrefix: os.Getenv("ARCHIVE_PREFIX"),
+	}
+	return pp, pp.validate()
+}
+
+func (pp PostProcess) validate() error {
+	switch pp.Action {
+	case PostActionNone, PostActionTag, PostActionDelete:
+		return nil
+	case PostActionCopy, PostActionMove:
+		if pp.ArchiveBucket == "" && pp.ArchivePrefix == "" {
+			return fmt.Errorf("%s requires ARCHIVE_BUCKET or ARCHIVE_PREFIX", pp.Action)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid POST_PROCESS action: %q (use 'tag', 'copy', 'move' or 'delete')", pp.Action)
+	}
+}
+
+// isArchived reports whether obj is a copy made by the copy or move action.
+// Such objects trigger their own notifications when archived in the source bucket.
+func (pp PostProcess) isArchived(obj types.S3ObjectInfo) bool {
+	if (pp.Action != PostActionCopy && pp.Action != PostActionMove) || pp.ArchivePrefix == "" {
+		return false
+	}
+	if pp.ArchiveBucket != "" && pp.ArchiveBucket != obj.Bucket {
+		return false
+	}
+	return strings.HasPrefix(obj.Key, pp.ArchivePrefix)
+}
+
+// postProcess applies the configured action to an object whose entries all
+// destinations have accepted.
+func (p *LogProcessor) postProcess(obj types.S3ObjectInfo, etag string) error {
+	switch p.post.Action {
+	case PostActionTag:
+		return p.tagObject(obj)
+	case PostActionCopy:
+		return p.archiveObject(obj, etag)
+	case PostActionMove:
+		if err := p.archiveObject(obj, etag); err != nil {
+			return err
+		}
+		return p.deleteObject(obj, etag)
+	case PostActionDelete:
+		return p.deleteObject(obj, etag)
+	}
+	return nil
+}
+
+// tagObject adds the forwarded tags, keeping existing tags since
+// PutObjectTagging replaces the whole tag set.
+func (p *LogProcessor) tagObject(obj types.S3ObjectInfo) error {
+	resp, err := p.s3.GetObjectTagging(&s3.GetObjectTaggingInput{
+		Bucket: aws.String(obj.Bucket),
+		Key:    aws.String(obj.Key),
+	})
+	if err != nil {
+		return fmt.Errorf("get object tagging: %w", err)
+	}
+
+	tags := []*s3.Tag{
+		{Key: aws.String(forwardedTag), Value: aws.String("true")},
+		{Key: aws.String(forwardedAtTag), Value: aws.String(time.Now().UTC().Format(time.RFC3339))},
+	}
+	for _, t := range resp.TagSet {
+		if k := aws.StringValue(t.Key); k != forwardedTag && k != forwardedAtTag {
+			tags = append(tags, t)
+		}
+	}
+
+	_, err = p.s3.PutObjectTagging(&s3.PutObjectTaggingInput{
+		Bucket:  aws.String(obj.Bucket),
+		Key:     aws.String(obj.Key),
+		Tagging: &s3.Tagging{TagSet: tags},
+	})
+	if err != nil {
+		return fmt.Errorf("put object tagging: %w", err)
+	}
+	return nil
+}
+
+// archiveObject copies the object to the archive location. The copy only
+// succeeds if the object still has the ETag that was forwarded.
+func (p *LogProcessor) archiveObject(obj types.S3ObjectInfo, etag string) error {
+	bucket := p.post.ArchiveBucket
+	if bucket == "" {
+		bucket = obj.Bucket
+	}
+	key := p.post.ArchivePrefix + obj.Key
+	if bucket == obj.Bucket && key == obj.Key {
+		return fmt.Errorf("archive location is the object itself; set ARCHIVE_PREFIX")
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(url.PathEscape(obj.Bucket) + "/" + escapeKey(obj.Key)),
+	}
+	if etag != "" {
+		input.CopySourceIfMatch = aws.String(etag)
+	}
+
+	if _, err := p.s3.CopyObject(input); err != nil {
+		return fmt.Errorf("copy to s3://%s/%s: %w", bucket, key, err)
+	}
+	slog.Info("archived", "bucket", obj.Bucket, "key", obj.Key, "archive", "s3://"+bucket+"/"+key)
+	return nil
+}
+
+// deleteObject deletes the object if it still has the ETag that was
+// forwarded, so an object overwritten in the meantime is kept. DeleteObject
+// has no precondition, so the ETag is checked with HeadObject first.
+func (p *LogProcessor) deleteObject(obj types.S3ObjectInfo, etag string) error {
+	if etag != "" {
+		_, err := p.s3.HeadObject(&s3.HeadObjectInput{
+			Bucket:  aws.String(obj.Bucket),
+			Key:     aws.String(obj.Key),
+			IfMatch: aws.String(etag),
+		})
+		if err != nil {
+			return fmt.Errorf("check object is unchanged: %w", err)
+		}
+	}
+
+	_, err := p.s3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(obj.Bucket),
+		Key:    aws.String(obj.Key),
+	})
+	if err != nil {
+		return fmt.Errorf("delete object: %w", err)
+	}
+	slog.Info("deleted", "bucket", obj.Bucket, "key", obj.Key)
+	return nil
+}
+
+// escapeKey URL-encodes each path segment of an object key for use in CopySource.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
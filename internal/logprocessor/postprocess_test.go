@@ -0,0 +1,151 @@
+package logprocessor
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostProcessValidate(t *testing.T) {
+	assert.NoError(t, PostProcess{}.validate())
+	assert.NoError(t, PostProcess{Action: PostActionTag}.validate())
+	assert.NoError(t, PostProcess{Action: PostActionMove, ArchivePrefix: "archive/"}.validate())
+	assert.Error(t, PostProcess{Action: PostActionCopy}.validate())
+	assert.Error(t, PostProcess{Action: "shred"}.validate())
+}
+
+func TestPostProcessIsArchived(t *testing.T) {
+	pp := PostProcess{Action: PostActionMove, ArchivePrefix: "archive/"}
+	assert.True(t, pp.isArchived(types.S3ObjectInfo{Bucket: "logs", Key: "archive/AWSLogs/file.log.gz"}))
+	assert.False(t, pp.isArchived(types.S3ObjectInfo{Bucket: "logs", Key: "AWSLogs/file.log.gz"}))
+
+	pp.ArchiveBucket = "archive-bucket"
+	assert.False(t, pp.isArchived(types.S3ObjectInfo{Bucket: "logs", Key: "archive/AWSLogs/file.log.gz"}))
+
+	assert.False(t, PostProcess{Action: PostActionTag}.isArchived(types.S3ObjectInfo{Bucket: "logs", Key: "archive/x"}))
+}
+
+func TestProcessLogsPostProcess(t *testing.T) {
+	obj := types.S3ObjectInfo{Bucket: "test-bucket", Key: "AWSLogs/my file.log.gz"}
+
+	newProcessor := func(t *testing.T, pp PostProcess) (*LogProcessor, *MockS3API) {
+		mockS3 := new(MockS3API)
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+			ETag: aws.String(`"abc"`),
+		}, nil).Once()
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{&MockDestination{}})
+		lp.post = pp
+		return lp, mockS3
+	}
+	unchanged := mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return *input.Key == obj.Key && aws.StringValue(input.IfMatch) == `"abc"`
+	})
+
+	t.Run("Tag keeps existing tags", func(t *testing.T) {
+		lp, mockS3 := newProcessor(t, PostProcess{Action: PostActionTag})
+
+		mockS3.On("GetObjectTagging", mock.Anything).Return(&s3.GetObjectTaggingOutput{
+			TagSet: []*s3.Tag{
+				{Key: aws.String("team"), Value: aws.String("platform")},
+				{Key: aws.String("forwarded"), Value: aws.String("false")},
+			},
+		}, nil).Once()
+		mockS3.On("PutObjectTagging", mock.MatchedBy(func(input *s3.PutObjectTaggingInput) bool {
+			tags := make(map[string]string)
+			for _, t := range input.Tagging.TagSet {
+				tags[*t.Key] = *t.Value
+			}
+			return len(tags) == 3 && tags["forwarded"] == "true" && tags["forwarded-at"] != "" && tags["team"] == "platform"
+		})).Return(&s3.PutObjectTaggingOutput{}, nil).Once()
+
+		require.NoError(t, lp.ProcessLogs(context.Background(), obj))
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Move copies with ETag precondition and deletes", func(t *testing.T) {
+		lp, mockS3 := newProcessor(t, PostProcess{Action: PostActionMove, ArchiveBucket: "archive", ArchivePrefix: "forwarded/"})
+
+		mockS3.On("CopyObject", mock.MatchedBy(func(input *s3.CopyObjectInput) bool {
+			return *input.Bucket == "archive" &&
+				*input.Key == "forwarded/AWSLogs/my file.log.gz" &&
+				*input.CopySource == "test-bucket/AWSLogs/my%20file.log.gz" &&
+				*input.CopySourceIfMatch == `"abc"`
+		})).Return(&s3.CopyObjectOutput{}, nil).Once()
+		mockS3.On("HeadObject", unchanged).Return(&s3.HeadObjectOutput{}, nil).Once()
+		mockS3.On("DeleteObject", mock.MatchedBy(func(input *s3.DeleteObjectInput) bool {
+			return *input.Bucket == "test-bucket" && *input.Key == obj.Key
+		})).Return(&s3.DeleteObjectOutput{}, nil).Once()
+
+		require.NoError(t, lp.ProcessLogs(context.Background(), obj))
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Failed copy does not delete", func(t *testing.T) {
+		lp, mockS3 := newProcessor(t, PostProcess{Action: PostActionMove, ArchivePrefix: "archive/"})
+
+		mockS3.On("CopyObject", mock.Anything).Return((*s3.CopyObjectOutput)(nil), assert.AnError).Once()
+
+		require.NoError(t, lp.ProcessLogs(context.Background(), obj))
+		mockS3.AssertNotCalled(t, "DeleteObject", mock.Anything)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		lp, mockS3 := newProcessor(t, PostProcess{Action: PostActionDelete})
+
+		mockS3.On("HeadObject", unchanged).Return(&s3.HeadObjectOutput{}, nil).Once()
+		mockS3.On("DeleteObject", mock.Anything).Return(&s3.DeleteObjectOutput{}, nil).Once()
+
+		require.NoError(t, lp.ProcessLogs(context.Background(), obj))
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Overwritten object is not deleted", func(t *testing.T) {
+		lp, mockS3 := newProcessor(t, PostProcess{Action: PostActionDelete})
+
+		mockS3.On("HeadObject", unchanged).Return((*s3.HeadObjectOutput)(nil), awserr.New("PreconditionFailed", "precondition failed", nil)).Once()
+
+		require.NoError(t, lp.ProcessLogs(context.Background(), obj))
+		mockS3.AssertNotCalled(t, "DeleteObject", mock.Anything)
+	})
+
+	t.Run("Failed delivery skips post-processing", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+			ETag: aws.String(`"abc"`),
+		}, nil).Once()
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{&MockDestination{}, &MockDestination{err: assert.AnError}})
+		lp.post = PostProcess{Action: PostActionDelete}
+
+		err := lp.ProcessLogs(context.Background(), obj)
+		require.ErrorIs(t, err, assert.AnError)
+		mockS3.AssertNotCalled(t, "HeadObject", mock.Anything)
+		mockS3.AssertNotCalled(t, "DeleteObject", mock.Anything)
+	})
+
+	t.Run("All records rejected skips post-processing", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(gzipData(t, []byte("not a log record\n"))),
+			ETag: aws.String(`"abc"`),
+		}, nil).Once()
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{&MockDestination{}})
+		lp.post = PostProcess{Action: PostActionDelete}
+
+		require.NoError(t, lp.ProcessLogs(context.Background(), obj))
+		mockS3.AssertNotCalled(t, "DeleteObject", mock.Anything)
+	})
+}
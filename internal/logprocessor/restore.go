@@ -0,0 +1,169 @@
+package logprocessor
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// archiveStorageClasses are the storage classes whose objects must be
+// restored before they can be read.
+var archiveStorageClasses = map[string]bool{
+	s3.ObjectStorageClassGlacier:     true,
+	s3.ObjectStorageClassDeepArchive: true,
+}
+
+// mayBeArchived reports whether objects of a storage class may have to be
+// restored before they can be read. Intelligent-Tiering objects only do once
+// moved to the Archive Access or Deep Archive Access tier, which listing does
+// not show.
+func mayBeArchived(storageClass string) bool {
+	return archiveStorageClasses[storageClass] || storageClass == s3.ObjectStorageClassIntelligentTiering
+}
+
+// RestoreTiers are the accepted retrieval tiers for restores.
+var RestoreTiers = []string{s3.TierBulk, s3.TierStandard, s3.TierExpedited}
+
+// ArchivedObjects handles objects in archive storage classes found while
+// listing. By default they are skipped. With Restore, objects that have
+// been restored are processed and a restore is requested for the others.
+// Objects that are skipped or awaiting restore are reported as pending.
+// A nil *ArchivedObjects skips them.
+type ArchivedObjects struct {
+	Restore     bool
+	RestoreDays int64
+	RestoreTier string
+
+	// PendingFile, if set, receives the pending objects as s3:// URLs, one
+	// per line, which can be passed as a manifest once restored.
+	PendingFile string
+
+	mu      sync.Mutex
+	pending []string
+}
+
+func (a *ArchivedObjects) restoring() bool {
+	return a != nil && a.Restore
+}
+
+// skip reports an archived object that is not processed.
+func (a *ArchivedObjects) skip(obj types.S3ObjectInfo, storageClass string) {
+	slog.Warn("skipping archived object", "bucket", obj.Bucket, "key", obj.Key, "storage_class", storageClass)
+	a.addPending(obj)
+}
+
+func (a *ArchivedObjects) addPending(obj types.S3ObjectInfo) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(a.pending, objectID(obj.Bucket, obj.Key))
+}
+
+// Pending returns the archived objects that were not processed.
+func (a *ArchivedObjects) Pending() []string {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pending := append([]string(nil), a.pending...)
+	sort.Strings(pending)
+	return pending
+}
+
+// Report logs how many archived objects were not processed and writes them
+// to PendingFile.
+func (a *ArchivedObjects) Report() error {
+	pending := a.Pending()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if a.Restore {
+		slog.Warn("archived objects awaiting restore, rerun once restored", "objects", len(pending))
+	} else {
+		slog.Warn("archived objects skipped", "objects", len(pending))
+	}
+
+	if a.PendingFile == "" {
+		return nil
+	}
+	if err := os.WriteFile(a.PendingFile, []byte(strings.Join(pending, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("write pending objects: %w", err)
+	}
+	slog.Info("pending objects written", "path", a.PendingFile)
+	return nil
+}
+
+// restore reports whether an archived object has been restored and can be
+// read. Otherwise it requests a restore, unless one is already in progress,
+// and records the object as pending. Intelligent-Tiering objects outside the
+// archive access tiers can be read right away; those inside are skipped
+// unless restoring.
+func (p *LogProcessor) restore(a *ArchivedObjects, obj types.S3ObjectInfo, storageClass string) (bool, error) {
+	head, err := p.s3.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(obj.Bucket),
+		Key:    aws.String(obj.Key),
+	})
+	if err != nil {
+		return false, fmt.Errorf("head s3://%s/%s: %w", obj.Bucket, obj.Key, err)
+	}
+
+	tiering := storageClass == s3.ObjectStorageClassIntelligentTiering
+	if tiering {
+		// The archive status is cleared once a restore completes
+		if head.ArchiveStatus == nil {
+			return true, nil
+		}
+		storageClass += "/" + aws.StringValue(head.ArchiveStatus)
+		if !a.restoring() {
+			a.skip(obj, storageClass)
+			return false, nil
+		}
+	}
+
+	// The Restore header is absent until a restore is requested
+	status := aws.StringValue(head.Restore)
+	switch {
+	case strings.Contains(status, `ongoing-request="false"`):
+		return true, nil
+	case strings.Contains(status, `ongoing-request="true"`):
+		slog.Info("restore in progress", "bucket", obj.Bucket, "key", obj.Key, "storage_class", storageClass)
+		a.addPending(obj)
+		return false, nil
+	}
+
+	// Intelligent-Tiering restores take neither days nor a retrieval tier:
+	// the object moves back to the Frequent Access tier
+	req := &s3.RestoreRequest{}
+	if !tiering {
+		req.Days = aws.Int64(a.RestoreDays)
+		req.GlacierJobParameters = &s3.GlacierJobParameters{
+			Tier: aws.String(a.RestoreTier),
+		}
+	}
+	_, err = p.s3.RestoreObject(&s3.RestoreObjectInput{
+		Bucket:         aws.String(obj.Bucket),
+		Key:            aws.String(obj.Key),
+		RestoreRequest: req,
+	})
+	var aerr awserr.Error
+	if err != nil && !(errors.As(err, &aerr) && aerr.Code() == "RestoreAlreadyInProgress") {
+		return false, fmt.Errorf("restore s3://%s/%s: %w", obj.Bucket, obj.Key, err)
+	}
+
+	slog.Info("restore requested", "bucket", obj.Bucket, "key", obj.Key, "storage_class", storageClass, "tier", a.RestoreTier)
+	a.addPending(obj)
+	return false, nil
+}
@@ -0,0 +1,200 @@
+package logprocessor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func headKeyIs(key string) any {
+	return mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return *input.Key == key
+	})
+}
+
+func TestHandleS3URLArchived(t *testing.T) {
+	listing := &s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String("logs/a.log.gz"), StorageClass: aws.String(s3.ObjectStorageClassStandard)},
+			{Key: aws.String("logs/b.log.gz"), StorageClass: aws.String(s3.ObjectStorageClassGlacier)},
+			{Key: aws.String("logs/c.log.gz"), StorageClass: aws.String(s3.ObjectStorageClassDeepArchive)},
+			{Key: aws.String("logs/d.log.gz"), StorageClass: aws.String(s3.ObjectStorageClassGlacier)},
+			{Key: aws.String("logs/e.log.gz"), StorageClass: aws.String(s3.ObjectStorageClassGlacierIr)},
+		},
+		IsTruncated: aws.Bool(false),
+	}
+
+	t.Run("Skipped and reported by default", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+		dir := t.TempDir()
+
+		mockS3.On("ListObjectsV2", mock.Anything).Return(listing, nil).Once()
+		for _, key := range []string{"logs/a.log.gz", "logs/e.log.gz"} {
+			mockS3.On("GetObject", keyIs(key)).Return(&s3.GetObjectOutput{
+				Body: io.NopCloser(loadTestData(t)),
+			}, nil).Once()
+		}
+
+		cp, err := OpenCheckpoint(filepath.Join(dir, "checkpoint.json"), false)
+		require.NoError(t, err)
+		archived := &ArchivedObjects{PendingFile: filepath.Join(dir, "pending.txt")}
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+		err = lp.HandleS3URL(context.Background(), "s3://my-bucket/logs/", BackfillOptions{Checkpoint: cp, Archived: archived})
+		require.NoError(t, err)
+		require.NoError(t, cp.Save())
+		require.NoError(t, archived.Report())
+
+		assert.Len(t, mockDest.Entries(), 10)
+		mockS3.AssertExpectations(t)
+		mockS3.AssertNotCalled(t, "RestoreObject", mock.Anything)
+
+		pending, err := os.ReadFile(archived.PendingFile)
+		require.NoError(t, err)
+		assert.Equal(t, "s3://my-bucket/logs/b.log.gz\ns3://my-bucket/logs/c.log.gz\ns3://my-bucket/logs/d.log.gz\n", string(pending))
+
+		// Skipped objects are not done, so a resumed run lists them again
+		f := readCheckpoint(t, cp.path)
+		assert.Equal(t, "logs/a.log.gz", f.Listings["s3://my-bucket/logs/"])
+		assert.Equal(t, []string{"s3://my-bucket/logs/e.log.gz"}, f.Completed)
+	})
+
+	t.Run("Restore requested or processed once restored", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		mockS3.On("ListObjectsV2", mock.Anything).Return(listing, nil).Once()
+		for _, key := range []string{"logs/a.log.gz", "logs/b.log.gz", "logs/e.log.gz"} {
+			mockS3.On("GetObject", keyIs(key)).Return(&s3.GetObjectOutput{
+				Body: io.NopCloser(loadTestData(t)),
+			}, nil).Once()
+		}
+
+		mockS3.On("HeadObject", headKeyIs("logs/b.log.gz")).Return(&s3.HeadObjectOutput{
+			Restore: aws.String(`ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`),
+		}, nil).Once()
+		mockS3.On("HeadObject", headKeyIs("logs/c.log.gz")).Return(&s3.HeadObjectOutput{}, nil).Once()
+		mockS3.On("HeadObject", headKeyIs("logs/d.log.gz")).Return(&s3.HeadObjectOutput{
+			Restore: aws.String(`ongoing-request="true"`),
+		}, nil).Once()
+
+		mockS3.On("RestoreObject", mock.MatchedBy(func(input *s3.RestoreObjectInput) bool {
+			return *input.Key == "logs/c.log.gz" &&
+				*input.RestoreRequest.Days == 3 &&
+				*input.RestoreRequest.GlacierJobParameters.Tier == s3.TierBulk
+		})).Return(&s3.RestoreObjectOutput{}, nil).Once()
+
+		archived := &ArchivedObjects{Restore: true, RestoreDays: 3, RestoreTier: s3.TierBulk}
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+		err := lp.HandleS3URL(context.Background(), "s3://my-bucket/logs/", BackfillOptions{Archived: archived})
+		require.NoError(t, err)
+
+		assert.Len(t, mockDest.Entries(), 15)
+		mockS3.AssertExpectations(t)
+		assert.Equal(t, []string{"s3://my-bucket/logs/c.log.gz", "s3://my-bucket/logs/d.log.gz"}, archived.Pending())
+	})
+
+	t.Run("Intelligent-Tiering archive access tiers", func(t *testing.T) {
+		listing := &s3.ListObjectsV2Output{
+			Contents: []*s3.Object{
+				{Key: aws.String("logs/a.log.gz"), StorageClass: aws.String(s3.ObjectStorageClassIntelligentTiering)},
+				{Key: aws.String("logs/b.log.gz"), StorageClass: aws.String(s3.ObjectStorageClassIntelligentTiering)},
+			},
+			IsTruncated: aws.Bool(false),
+		}
+		run := func(t *testing.T, archived *ArchivedObjects) *MockS3API {
+			mockS3 := new(MockS3API)
+			mockDest := &MockDestination{}
+
+			mockS3.On("ListObjectsV2", mock.Anything).Return(listing, nil).Once()
+			mockS3.On("HeadObject", headKeyIs("logs/a.log.gz")).Return(&s3.HeadObjectOutput{}, nil).Once()
+			mockS3.On("HeadObject", headKeyIs("logs/b.log.gz")).Return(&s3.HeadObjectOutput{
+				ArchiveStatus: aws.String(s3.ArchiveStatusDeepArchiveAccess),
+			}, nil).Once()
+			mockS3.On("GetObject", keyIs("logs/a.log.gz")).Return(&s3.GetObjectOutput{
+				Body: io.NopCloser(loadTestData(t)),
+			}, nil).Once()
+
+			lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+			err := lp.HandleS3URL(context.Background(), "s3://my-bucket/logs/", BackfillOptions{Archived: archived})
+			require.NoError(t, err)
+
+			assert.Len(t, mockDest.Entries(), 5)
+			assert.Equal(t, []string{"s3://my-bucket/logs/b.log.gz"}, archived.Pending())
+			return mockS3
+		}
+
+		t.Run("Skipped by default", func(t *testing.T) {
+			mockS3 := run(t, &ArchivedObjects{})
+			mockS3.AssertExpectations(t)
+			mockS3.AssertNotCalled(t, "RestoreObject", mock.Anything)
+		})
+
+		t.Run("Restored without days or tier", func(t *testing.T) {
+			mockS3 := new(MockS3API)
+			mockS3.On("HeadObject", headKeyIs("logs/b.log.gz")).Return(&s3.HeadObjectOutput{
+				ArchiveStatus: aws.String(s3.ArchiveStatusArchiveAccess),
+			}, nil).Once()
+			mockS3.On("RestoreObject", mock.MatchedBy(func(input *s3.RestoreObjectInput) bool {
+				return *input.Key == "logs/b.log.gz" &&
+					input.RestoreRequest.Days == nil &&
+					input.RestoreRequest.GlacierJobParameters == nil
+			})).Return(&s3.RestoreObjectOutput{}, nil).Once()
+
+			archived := &ArchivedObjects{Restore: true, RestoreDays: 7, RestoreTier: s3.TierBulk}
+
+			lp := NewWithDeps(mockS3, nil, nil)
+			restored, err := lp.restore(archived, types.S3ObjectInfo{Bucket: "my-bucket", Key: "logs/b.log.gz"}, s3.ObjectStorageClassIntelligentTiering)
+			require.NoError(t, err)
+			assert.False(t, restored)
+			assert.Equal(t, []string{"s3://my-bucket/logs/b.log.gz"}, archived.Pending())
+			mockS3.AssertExpectations(t)
+		})
+	})
+
+	t.Run("Restore already in progress", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockS3.On("HeadObject", mock.Anything).Return(&s3.HeadObjectOutput{}, nil).Once()
+		mockS3.On("RestoreObject", mock.Anything).Return(
+			(*s3.RestoreObjectOutput)(nil),
+			awserr.New("RestoreAlreadyInProgress", "Object restore is already in progress", nil),
+		).Once()
+
+		archived := &ArchivedObjects{Restore: true, RestoreDays: 7, RestoreTier: s3.TierBulk}
+		lp := NewWithDeps(mockS3, nil, nil)
+
+		restored, err := lp.restore(archived, types.S3ObjectInfo{Bucket: "my-bucket", Key: "logs/b.log.gz"}, s3.ObjectStorageClassGlacier)
+		require.NoError(t, err)
+		assert.False(t, restored)
+		assert.Len(t, archived.Pending(), 1)
+	})
+
+	t.Run("Restore failure stops the run", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockS3.On("HeadObject", mock.Anything).Return(&s3.HeadObjectOutput{}, nil).Once()
+		mockS3.On("RestoreObject", mock.Anything).Return(
+			(*s3.RestoreObjectOutput)(nil),
+			awserr.New("AccessDenied", "Access Denied", nil),
+		).Once()
+
+		archived := &ArchivedObjects{Restore: true, RestoreDays: 7, RestoreTier: s3.TierBulk}
+		lp := NewWithDeps(mockS3, nil, nil)
+
+		_, err := lp.restore(archived, types.S3ObjectInfo{Bucket: "my-bucket", Key: "logs/b.log.gz"}, s3.ObjectStorageClassGlacier)
+		require.Error(t, err)
+		assert.Empty(t, archived.Pending())
+	})
+}
@@ -0,0 +1,144 @@
+package logprocessor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// S3 server access log fields in order.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/LogFormat.html
+var s3AccessFields = []string{
+	"bucket_owner",
+	"bucket",
+	"time",
+	"remote_ip",
+	"requester",
+	"request_id",
+	"operation",
+	"key",
+	"request_uri",
+	"http_status",
+	"error_code",
+	"bytes_sent",
+	"object_size",
+	"total_time",
+	"turn_around_time",
+	"referer",
+	"user_agent",
+	"version_id",
+	"host_id",
+	"signature_version",
+	"cipher_suite",
+	"authentication_type",
+	"host_header",
+	"tls_version",
+	"access_point_arn",
+	"acl_required",
+}
+
+const (
+	s3AccessTimeIdx    = 2
+	s3AccessTimeLayout = "02/Jan/2006:15:04:05 -0700"
+)
+
+// s3AccessParser parses S3 server access logs: space-separated fields, with
+// the time in brackets and the request URI, referer and user agent quoted.
+type s3AccessParser struct {
+	fields *FieldFilter
+}
+
+func newS3AccessParser(fieldConfig string, opts FieldOptions) (Parser, error) {
+	fields, err := NewFieldFilter(LBTypeS3Access, fieldConfig, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &s3AccessParser{fields: fields}, nil
+}
+
+func (p *s3AccessParser) Parse(r io.Reader, out chan<- types.LogEntry, reject RejectFunc) (ParseStats, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	stats := ParseStats{Type: LBTypeS3Access}
+
+	for line := 1; scanner.Scan(); line++ {
+		raw := scanner.Text()
+		if raw == "" {
+			continue
+		}
+		record, ts, err := parseS3AccessRecord(raw)
+		if err != nil {
+			if err := stats.reject(reject, line, raw, err); err != nil {
+				return stats, err
+			}
+			continue
+		}
+
+		data := make(map[string]any)
+		for i, val := range record {
+			if name, ok := p.fields.field(i); ok {
+				data[name] = p.fields.value(name, val)
+			}
+		}
+		stats.count(len(record), p.fields.TotalFields())
+		out <- types.LogEntry{Data: data, Timestamp: ts}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("read record: %w", err)
+	}
+	return stats, nil
+}
+
+// parseS3AccessRecord splits an S3 access log line into its fields and
+// returns them with the request time.
+func parseS3AccessRecord(line string) ([]string, time.Time, error) {
+	record, err := splitS3AccessRecord(line)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(record) <= s3AccessTimeIdx {
+		return nil, time.Time{}, fmt.Errorf("record too short: need at least %d fields for timestamp, got %d", s3AccessTimeIdx+1, len(record))
+	}
+	ts, err := time.Parse(s3AccessTimeLayout, record[s3AccessTimeIdx])
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("parse timestamp: %w", err)
+	}
+	return record, ts, nil
+}
+
+// splitS3AccessRecord splits an S3 access log line into its fields, removing
+// the quotes and brackets around values.
+func splitS3AccessRecord(line string) ([]string, error) {
+	var record []string
+	for line = strings.TrimLeft(line, " "); line != ""; line = strings.TrimLeft(line, " ") {
+		var end byte = ' '
+		switch line[0] {
+		case '"':
+			end = '"'
+		case '[':
+			end = ']'
+		}
+
+		if end == ' ' {
+			i := strings.IndexByte(line, ' ')
+			if i < 0 {
+				i = len(line)
+			}
+			record = append(record, line[:i])
+			line = line[i:]
+			continue
+		}
+
+		i := strings.IndexByte(line[1:], end)
+		if i < 0 {
+			return nil, fmt.Errorf("unterminated %q in field %d", line[0], len(record)+1)
+		}
+		record = append(record, line[1:i+1])
+		line = line[i+2:]
+	}
+	return record, nil
+}
@@ -0,0 +1,41 @@
+package logprocessor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const s3AccessLog = `79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be awsexamplebucket1 [06/Feb/2019:00:00:38 +0000] 192.0.2.3 79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be 3E57427F3EXAMPLE REST.GET.VERSIONING - "GET /awsexamplebucket1?versioning HTTP/1.1" 200 - 113 - 7 - "-" "S3Console/0.4" - s9lzHYrFp76ZVxRcpX9+5cjAnEH2ROuNkd2BHfIa6UkFVdtjf5mKR3/eTPFvsiP/XV/VLi31234= SigV4 ECDHE-RSA-AES128-GCM-SHA256 AuthHeader awsexamplebucket1.s3.us-west-1.amazonaws.com TLSV1.2 - -
+79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be awsexamplebucket1 [06/Feb/2019:00:00:39 +0100] 192.0.2.3 - 891CE47D2EXAMPLE REST.GET.OBJECT logs/a%20b.txt "GET /awsexamplebucket1/logs/a%20b.txt HTTP/1.1" 404 NoSuchKey 243 - 12 - "https://example.com/" "aws-sdk-go/1.44 (go1.22; linux)" -
+`
+
+func TestS3AccessParser(t *testing.T) {
+	t.Run("Server access log", func(t *testing.T) {
+		parser, err := NewParser(LBTypeS3Access, "", FieldOptions{})
+		require.NoError(t, err)
+
+		entries, err := parseAll(t, parser, s3AccessLog)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		assert.Equal(t, time.Date(2019, 2, 6, 0, 0, 38, 0, time.UTC), entries[0].Timestamp.UTC())
+		assert.Equal(t, "GET /awsexamplebucket1?versioning HTTP/1.1", entries[0].Data["request_uri"])
+		assert.Equal(t, "S3Console/0.4", entries[0].Data["user_agent"])
+		assert.Equal(t, "TLSV1.2", entries[0].Data["tls_version"])
+		assert.Len(t, entries[0].Data, 26)
+
+		// Older records have fewer fields
+		assert.Equal(t, time.Date(2019, 2, 5, 23, 0, 39, 0, time.UTC), entries[1].Timestamp.UTC())
+		assert.Equal(t, "NoSuchKey", entries[1].Data["error_code"])
+		assert.Equal(t, "aws-sdk-go/1.44 (go1.22; linux)", entries[1].Data["user_agent"])
+		assert.Len(t, entries[1].Data, 18)
+	})
+
+	t.Run("Unterminated quote", func(t *testing.T) {
+		_, err := splitS3AccessRecord(`owner bucket [06/Feb/2019:00:00:38 +0000] "GET /`)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,59 @@
+package logprocessor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+	"golang.org/x/sync/errgroup"
+)
+
+// HandleSQSEvent processes S3 notifications delivered through an SQS queue.
+// Message bodies may carry the notification directly, wrapped in an SNS
+// envelope, or as an EventBridge event. Messages with at least one failed
+// object are reported as batch item failures, so only those are redelivered
+// (requires ReportBatchItemFailures on the trigger).
+func (p *LogProcessor) HandleSQSEvent(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	var mu sync.Mutex
+	failed := make(map[string]bool)
+	markFailed := func(id string) {
+		mu.Lock()
+		failed[id] = true
+		mu.Unlock()
+	}
+
+	// Errors are tracked per message, so a failing object must not cancel the others
+	var g errgroup.Group
+	g.SetLimit(maxConcurrency)
+
+	for _, msg := range event.Records {
+		objs, err := notificationObjects([]byte(msg.Body))
+		if err != nil {
+			slog.Error("invalid SQS message body", "message_id", msg.MessageId, "error", err)
+			markFailed(msg.MessageId)
+			continue
+		}
+
+		for _, obj := range objs {
+			g.Go(func() error {
+				if err := p.processObject(ctx, obj); err != nil {
+					markFailed(msg.MessageId)
+				}
+				return nil
+			})
+		}
+	}
+
+	g.Wait()
+
+	var resp events.SQSEventResponse
+	for _, msg := range event.Records {
+		if failed[msg.MessageId] {
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: msg.MessageId,
+			})
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,107 @@
+package logprocessor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// s3NotificationBody builds an S3 event notification as delivered in an SQS message body.
+func s3NotificationBody(bucket string, keys ...string) string {
+	body := `{"Records":[`
+	for i, key := range keys {
+		if i > 0 {
+			body += ","
+		}
+		body += fmt.Sprintf(`{"eventSource":"aws:s3","eventName":"ObjectCreated:Put","s3":{"bucket":{"name":%q},"object":{"key":%q}}}`, bucket, key)
+	}
+	return body + `]}`
+}
+
+func keyIs(key string) any {
+	return mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return *input.Key == key
+	})
+}
+
+func TestHandleSQSEvent(t *testing.T) {
+	t.Run("Process S3 notifications from all messages", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		mockS3.On("GetObject", keyIs("logs/file1.log.gz")).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+		mockS3.On("GetObject", keyIs("logs/file2.log.gz")).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+
+		event := events.SQSEvent{
+			Records: []events.SQSMessage{
+				{MessageId: "msg-1", Body: s3NotificationBody("test-bucket", "logs/file1.log.gz")},
+				{MessageId: "msg-2", Body: s3NotificationBody("test-bucket", "logs/file2.log.gz")},
+			},
+		}
+
+		resp, err := lp.HandleSQSEvent(context.Background(), event)
+		require.NoError(t, err)
+		assert.Empty(t, resp.BatchItemFailures)
+		assert.Len(t, mockDest.Entries(), 10)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Only failed messages are reported", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+
+		mockS3.On("GetObject", keyIs("logs/ok.log.gz")).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+		mockS3.On("GetObject", keyIs("logs/denied.log.gz")).Return(
+			(*s3.GetObjectOutput)(nil),
+			fmt.Errorf("access denied"),
+		)
+
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{mockDest})
+
+		event := events.SQSEvent{
+			Records: []events.SQSMessage{
+				{MessageId: "msg-ok", Body: s3NotificationBody("test-bucket", "logs/ok.log.gz")},
+				{MessageId: "msg-failed", Body: s3NotificationBody("test-bucket", "logs/denied.log.gz")},
+			},
+		}
+
+		resp, err := lp.HandleSQSEvent(context.Background(), event)
+		require.NoError(t, err)
+		require.Len(t, resp.BatchItemFailures, 1)
+		assert.Equal(t, "msg-failed", resp.BatchItemFailures[0].ItemIdentifier)
+		assert.Len(t, mockDest.Entries(), 5)
+	})
+
+	t.Run("Invalid message body is reported as failure", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		lp := NewWithDeps(mockS3, nil, []destinations.Destination{&MockDestination{}})
+
+		event := events.SQSEvent{
+			Records: []events.SQSMessage{
+				{MessageId: "msg-bad", Body: "not json"},
+			},
+		}
+
+		resp, err := lp.HandleSQSEvent(context.Background(), event)
+		require.NoError(t, err)
+		require.Len(t, resp.BatchItemFailures, 1)
+		assert.Equal(t, "msg-bad", resp.BatchItemFailures[0].ItemIdentifier)
+		mockS3.AssertNotCalled(t, "GetObject", mock.Anything)
+	})
+}
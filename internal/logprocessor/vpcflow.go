@@ -0,0 +1,134 @@
+package logprocessor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jdwit/aws-lb-log-forwarder/internal/types"
+)
+
+// VPC Flow Logs fields. The default format is the first 14; files delivered
+// to S3 start with a header naming the fields of their format.
+// https://docs.aws.amazon.com/vpc/latest/userguide/flow-log-records.html
+var vpcFlowFields = []string{
+	"version",
+	"account-id",
+	"interface-id",
+	"srcaddr",
+	"dstaddr",
+	"srcport",
+	"dstport",
+	"protocol",
+	"packets",
+	"bytes",
+	"start",
+	"end",
+	"action",
+	"log-status",
+	"vpc-id",
+	"subnet-id",
+	"instance-id",
+	"tcp-flags",
+	"type",
+	"pkt-srcaddr",
+	"pkt-dstaddr",
+	"region",
+	"az-id",
+	"sublocation-type",
+	"sublocation-id",
+	"pkt-src-aws-service",
+	"pkt-dst-aws-service",
+	"flow-direction",
+	"traffic-path",
+	"ecs-cluster-arn",
+	"ecs-cluster-name",
+	"ecs-container-instance-arn",
+	"ecs-container-instance-id",
+	"ecs-container-id",
+	"ecs-second-container-id",
+	"ecs-service-name",
+	"ecs-task-definition-arn",
+	"ecs-task-arn",
+	"ecs-task-id",
+	"reject-reason",
+}
+
+// vpcFlowDefaultFields is the number of fields of the default format.
+const vpcFlowDefaultFields = 14
+
+// vpcFlowParser parses space-separated VPC Flow Logs records, in the format
+// given by a header line or the default format otherwise. The start of the
+// aggregation interval is the entry timestamp.
+type vpcFlowParser struct {
+	fields *FieldFilter
+}
+
+func newVPCFlowParser(fieldConfig string, opts FieldOptions) (Parser, error) {
+	fields, err := NewFieldFilter(LBTypeVPCFlow, fieldConfig, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &vpcFlowParser{fields: fields}, nil
+}
+
+func (p *vpcFlowParser) Parse(r io.Reader, out chan<- types.LogEntry, reject RejectFunc) (ParseStats, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	stats := ParseStats{Type: LBTypeVPCFlow}
+
+	names := vpcFlowFields[:vpcFlowDefaultFields]
+	startIdx := -1
+
+	for line := 1; scanner.Scan(); line++ {
+		raw := scanner.Text()
+		record := strings.Fields(raw)
+		if len(record) == 0 {
+			continue
+		}
+
+		// Values never equal a field name, so a line naming one is the header
+		if line == 1 && slices.ContainsFunc(record, func(v string) bool { return slices.Contains(vpcFlowFields, v) }) {
+			names = record
+			slog.Debug("log format", "type", LBTypeVPCFlow, "fields", len(names))
+			continue
+		}
+		if startIdx < 0 {
+			if startIdx = slices.Index(names, "start"); startIdx < 0 {
+				return stats, fmt.Errorf("log format has no start field")
+			}
+		}
+
+		ts, err := vpcFlowTime(record, startIdx)
+		if err != nil {
+			if err := stats.reject(reject, line, raw, err); err != nil {
+				return stats, err
+			}
+			continue
+		}
+
+		stats.count(len(record), len(names))
+		out <- types.LogEntry{Data: namedData(p.fields, names, record), Timestamp: ts}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("read record: %w", err)
+	}
+	return stats, nil
+}
+
+// vpcFlowTime returns the start of the aggregation interval of a record.
+func vpcFlowTime(record []string, startIdx int) (time.Time, error) {
+	if len(record) <= startIdx {
+		return time.Time{}, fmt.Errorf("record too short: need at least %d fields for timestamp, got %d", startIdx+1, len(record))
+	}
+	start, err := strconv.ParseInt(record[startIdx], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse timestamp: %w", err)
+	}
+	return time.Unix(start, 0).UTC(), nil
+}
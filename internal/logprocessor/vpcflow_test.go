@@ -0,0 +1,60 @@
+package logprocessor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVPCFlowParser(t *testing.T) {
+	t.Run("Default format with header", func(t *testing.T) {
+		parser, err := NewParser(LBTypeVPCFlow, "", FieldOptions{})
+		require.NoError(t, err)
+
+		log := `version account-id interface-id srcaddr dstaddr srcport dstport protocol packets bytes start end action log-status
+2 123456789010 eni-1235b8ca123456789 172.31.16.139 172.31.16.21 20641 22 6 20 4249 1418530010 1418530070 ACCEPT OK
+2 123456789010 eni-1235b8ca123456789 - - - - - - - 1431280876 1431280934 - NODATA
+`
+		entries, err := parseAll(t, parser, log)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		assert.Equal(t, time.Date(2014, 12, 14, 4, 6, 50, 0, time.UTC), entries[0].Timestamp)
+		assert.Equal(t, "172.31.16.139", entries[0].Data["srcaddr"])
+		assert.Equal(t, "ACCEPT", entries[0].Data["action"])
+		assert.Equal(t, "NODATA", entries[1].Data["log-status"])
+	})
+
+	t.Run("Custom format", func(t *testing.T) {
+		parser, err := NewParser(LBTypeVPCFlow, "vpc-id,flow-direction", FieldOptions{})
+		require.NoError(t, err)
+
+		log := "vpc-id start flow-direction srcaddr\nvpc-0123 1418530010 ingress 10.0.0.1\n"
+		entries, err := parseAll(t, parser, log)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, time.Unix(1418530010, 0).UTC(), entries[0].Timestamp)
+		assert.Equal(t, map[string]any{"vpc-id": "vpc-0123", "flow-direction": "ingress"}, entries[0].Data)
+	})
+
+	t.Run("Without header", func(t *testing.T) {
+		parser, err := NewParser(LBTypeVPCFlow, "", FieldOptions{})
+		require.NoError(t, err)
+
+		entries, err := parseAll(t, parser, "2 123456789010 eni-1235b8ca123456789 172.31.9.69 172.31.9.12 49761 3389 6 20 4249 1418530010 1418530070 REJECT OK\n")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "REJECT", entries[0].Data["action"])
+		assert.Len(t, entries[0].Data, 14)
+	})
+
+	t.Run("Format without start", func(t *testing.T) {
+		parser, err := NewParser(LBTypeVPCFlow, "", FieldOptions{})
+		require.NoError(t, err)
+
+		_, err = parseAll(t, parser, "srcaddr dstaddr\n10.0.0.1 10.0.0.2\n")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,201 @@
+package logprocessor
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWebhookAddr        = ":8080"
+	defaultWebhookMaxInFlight = 100
+
+	maxWebhookBodySize     = 1 << 20
+	webhookShutdownTimeout = 10 * time.Second
+)
+
+// Webhook receives S3-style bucket notifications over HTTP, as sent by
+// MinIO, and processes the referenced objects before answering, so that the
+// sender retries notifications that failed.
+type Webhook struct {
+	proc        *LogProcessor
+	addr        string
+	secret      string
+	maxInFlight int
+
+	mu       sync.Mutex
+	inFlight int
+	ready    atomic.Bool
+}
+
+// NewWebhook creates a Webhook from environment configuration.
+func NewWebhook(proc *LogProcessor) (*Webhook, error) {
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("WEBHOOK_SECRET is required")
+	}
+
+	addr := os.Getenv("WEBHOOK_ADDR")
+	if addr == "" {
+		addr = defaultWebhookAddr
+	}
+
+	maxInFlight := defaultWebhookMaxInFlight
+	if v := os.Getenv("WEBHOOK_MAX_IN_FLIGHT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid WEBHOOK_MAX_IN_FLIGHT: %q", v)
+		}
+		maxInFlight = n
+	}
+
+	return NewWebhookWithDeps(proc, addr, secret, maxInFlight), nil
+}
+
+// NewWebhookWithDeps creates a Webhook with explicit dependencies (for testing).
+func NewWebhookWithDeps(proc *LogProcessor, addr, secret string, maxInFlight int) *Webhook {
+	return &Webhook{
+		proc:        proc,
+		addr:        addr,
+		secret:      secret,
+		maxInFlight: maxInFlight,
+	}
+}
+
+// Handler returns the HTTP handler: notifications are accepted with a POST
+// to any path, next to the /healthz and /readyz probes.
+func (w *Webhook) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /readyz", w.handleReady)
+	mux.HandleFunc("POST /", w.handleNotification)
+	return mux
+}
+
+// Run serves HTTP until ctx is cancelled. It then stops accepting
+// notifications and waits for those in progress to finish.
+func (w *Webhook) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", w.addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", w.addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           w.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	w.ready.Store(true)
+	slog.Info("webhook listening", "addr", ln.Addr().String())
+
+	select {
+	case <-ctx.Done():
+		err = nil
+	case err = <-errc:
+		err = fmt.Errorf("serve: %w", err)
+	}
+
+	w.ready.Store(false)
+	// Shutdown waits for in-progress notifications; those cut off by the
+	// timeout are not answered and get retried by the sender
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookShutdownTimeout)
+	defer cancel()
+	if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil && !errors.Is(shutdownErr, http.ErrServerClosed) {
+		slog.Error("webhook shutdown failed", "error", shutdownErr)
+	}
+	return err
+}
+
+func (w *Webhook) handleReady(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	busy := w.inFlight >= w.maxInFlight
+	w.mu.Unlock()
+
+	if !w.ready.Load() || busy {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (w *Webhook) handleNotification(rw http.ResponseWriter, r *http.Request) {
+	if !w.authorized(r.Header.Get("Authorization")) {
+		http.Error(rw, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxWebhookBodySize))
+	if err != nil {
+		http.Error(rw, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	objs, err := notificationObjects(payload)
+	if err != nil {
+		slog.Error("invalid webhook notification", "error", err)
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A notification with more objects than can ever be in progress takes
+	// all the room, so it still gets processed once the others finish
+	n := min(len(objs), w.maxInFlight)
+	if !w.acquire(n) {
+		// The sender retries, or keeps the event in its own queue
+		rw.Header().Set("Retry-After", "5")
+		http.Error(rw, "too many objects in progress", http.StatusServiceUnavailable)
+		return
+	}
+	defer w.release(n)
+
+	if err := w.proc.processObjects(r.Context(), objs); err != nil {
+		// Not acknowledged, so the sender retries the notification
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+// authorized checks the Authorization header against the shared secret.
+// MinIO sends its auth_token either as-is or as a bearer token.
+func (w *Webhook) authorized(header string) bool {
+	token := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(w.secret)) == 1
+}
+
+// acquire reserves room for n objects of a notification, or none if they do
+// not fit next to the objects already in progress.
+func (w *Webhook) acquire(n int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.inFlight+n > w.maxInFlight {
+		return false
+	}
+	w.inFlight += n
+	return true
+}
+
+// release frees the room reserved by acquire.
+func (w *Webhook) release(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.inFlight -= n
+}
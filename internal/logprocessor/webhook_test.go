@@ -0,0 +1,159 @@
+package logprocessor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jdwit/aws-lb-log-forwarder/internal/destinations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// minioNotificationBody is a bucket notification as sent by a MinIO webhook target.
+const minioNotificationBody = `{
+	"EventName": "s3:ObjectCreated:Put",
+	"Key": "logs/AWSLogs/app+log.gz",
+	"Records": [{
+		"eventVersion": "2.0",
+		"eventSource": "minio:s3",
+		"eventName": "s3:ObjectCreated:Put",
+		"s3": {
+			"bucket": {"name": "logs", "arn": "arn:aws:s3:::logs"},
+			"object": {"key": "AWSLogs%2Fapp+log.gz", "size": 1024, "eTag": "abc"}
+		}
+	}]
+}`
+
+func postNotification(t *testing.T, h http.Handler, auth, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWebhook(t *testing.T) {
+	t.Run("Processes MinIO notification", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+		mockS3.On("GetObject", keyIs("AWSLogs/app log.gz")).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+		w := NewWebhookWithDeps(NewWithDeps(mockS3, nil, []destinations.Destination{mockDest}), "", "s3cret", 10)
+
+		rec := postNotification(t, w.Handler(), "Bearer s3cret", minioNotificationBody)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Len(t, mockDest.Entries(), 5)
+		mockS3.AssertExpectations(t)
+		assert.Zero(t, w.inFlight)
+	})
+
+	t.Run("Accepts secret without bearer scheme", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+		for _, key := range []string{"a.log.gz", "b.log.gz"} {
+			mockS3.On("GetObject", keyIs(key)).Return(&s3.GetObjectOutput{
+				Body: io.NopCloser(loadTestData(t)),
+			}, nil).Once()
+		}
+		w := NewWebhookWithDeps(NewWithDeps(mockS3, nil, []destinations.Destination{mockDest}), "", "s3cret", 10)
+
+		rec := postNotification(t, w.Handler(), "s3cret", s3NotificationBody("logs", "a.log.gz", "b.log.gz"))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, mockDest.Entries(), 10)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("Failed processing is not acknowledged", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{err: assert.AnError}
+		mockS3.On("GetObject", keyIs("a.log.gz")).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(loadTestData(t)),
+		}, nil).Once()
+		w := NewWebhookWithDeps(NewWithDeps(mockS3, nil, []destinations.Destination{mockDest}), "", "s3cret", 10)
+
+		rec := postNotification(t, w.Handler(), "s3cret", s3NotificationBody("logs", "a.log.gz"))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, rec.Body.String(), "a.log.gz")
+		assert.Zero(t, w.inFlight)
+	})
+
+	t.Run("Rejects wrong or missing secret", func(t *testing.T) {
+		w := NewWebhookWithDeps(nil, "", "s3cret", 10)
+
+		assert.Equal(t, http.StatusUnauthorized, postNotification(t, w.Handler(), "Bearer nope", minioNotificationBody).Code)
+		assert.Equal(t, http.StatusUnauthorized, postNotification(t, w.Handler(), "", minioNotificationBody).Code)
+	})
+
+	t.Run("Rejects invalid payload", func(t *testing.T) {
+		w := NewWebhookWithDeps(nil, "", "s3cret", 10)
+
+		rec := postNotification(t, w.Handler(), "s3cret", "not json")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Too many objects in progress asks sender to retry", func(t *testing.T) {
+		w := NewWebhookWithDeps(nil, "", "s3cret", 2)
+		require.True(t, w.acquire(1))
+
+		rec := postNotification(t, w.Handler(), "s3cret", s3NotificationBody("logs", "a.log.gz", "b.log.gz"))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+		assert.Equal(t, 1, w.inFlight, "objects of a rejected notification are not reserved")
+	})
+
+	t.Run("Notification larger than the limit is processed", func(t *testing.T) {
+		mockS3 := new(MockS3API)
+		mockDest := &MockDestination{}
+		for _, key := range []string{"a.log.gz", "b.log.gz", "c.log.gz"} {
+			mockS3.On("GetObject", keyIs(key)).Return(&s3.GetObjectOutput{
+				Body: io.NopCloser(loadTestData(t)),
+			}, nil).Once()
+		}
+		w := NewWebhookWithDeps(NewWithDeps(mockS3, nil, []destinations.Destination{mockDest}), "", "s3cret", 2)
+
+		rec := postNotification(t, w.Handler(), "s3cret", s3NotificationBody("logs", "a.log.gz", "b.log.gz", "c.log.gz"))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, mockDest.Entries(), 15)
+		mockS3.AssertExpectations(t)
+		assert.Zero(t, w.inFlight)
+	})
+
+	t.Run("Health and readiness", func(t *testing.T) {
+		w := NewWebhookWithDeps(nil, "", "s3cret", 1)
+		get := func(path string) int {
+			rec := httptest.NewRecorder()
+			w.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			return rec.Code
+		}
+
+		assert.Equal(t, http.StatusOK, get("/healthz"))
+		assert.Equal(t, http.StatusServiceUnavailable, get("/readyz"), "not ready before serving")
+
+		w.ready.Store(true)
+		assert.Equal(t, http.StatusOK, get("/readyz"))
+
+		require.True(t, w.acquire(1))
+		assert.Equal(t, http.StatusServiceUnavailable, get("/readyz"), "not ready with no room for objects")
+		w.release(1)
+		assert.Equal(t, http.StatusOK, get("/readyz"))
+	})
+}
+
+func TestWebhookRunShutsDown(t *testing.T) {
+	w := NewWebhookWithDeps(nil, "127.0.0.1:0", "s3cret", 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, w.Run(ctx))
+	assert.False(t, w.ready.Load())
+}
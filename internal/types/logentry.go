@@ -2,8 +2,10 @@ package types
 
 import "time"
 
-// LogEntry represents a parsed ALB log entry with its timestamp.
+// LogEntry represents a parsed log entry with its timestamp. Field values
+// are strings, int64, float64, time.Time, slices and maps of these, or nil
+// for missing values.
 type LogEntry struct {
-	Data      map[string]string
+	Data      map[string]any
 	Timestamp time.Time
 }
@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -26,22 +31,48 @@ func main() {
 
 	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
 		slog.Info("starting lambda handler")
-		lambda.Start(proc.HandleLambdaEvent)
+		lambda.Start(lambdaHandler(proc))
 		return
 	}
 
-	if len(os.Args) < 2 {
-		slog.Error("usage: alb-log-forwarder <s3-url>")
-		os.Exit(1)
-	}
+	// Cancel on Ctrl-C or SIGTERM so in-flight work stops and progress is saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	slog.Info("processing S3 URL", "url", os.Args[1])
-	if err := proc.HandleS3URL(context.Background(), os.Args[1]); err != nil {
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "serve":
+		err = runServe(ctx, sess, proc)
+	case len(os.Args) > 1 && os.Args[1] == "webhook":
+		err = runWebhook(ctx, proc)
+	default:
+		err = runCLI(ctx, proc, os.Args[1:])
+	}
+	if err != nil {
 		slog.Error("processing failed", "error", err)
 		os.Exit(1)
 	}
 }
 
+// runServe polls the SQS queue configured in the environment until
+// interrupted, then finishes in-flight messages.
+func runServe(ctx context.Context, sess *session.Session, proc *logprocessor.LogProcessor) error {
+	poller, err := logprocessor.NewPoller(sess, proc)
+	if err != nil {
+		return fmt.Errorf("invalid serve config: %w", err)
+	}
+	return poller.Run(ctx)
+}
+
+// runWebhook receives bucket notifications over HTTP until interrupted,
+// then finishes the queued objects.
+func runWebhook(ctx context.Context, proc *logprocessor.LogProcessor) error {
+	webhook, err := logprocessor.NewWebhook(proc)
+	if err != nil {
+		return fmt.Errorf("invalid webhook config: %w", err)
+	}
+	return webhook.Run(ctx)
+}
+
 func newSession() (*session.Session, error) {
 	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
 		return session.NewSession(&aws.Config{
@@ -52,3 +83,55 @@ func newSession() (*session.Session, error) {
 	}
 	return session.NewSession()
 }
+
+// lambdaHandler detects the envelope of an invocation (S3, SNS, SQS or
+// EventBridge) and dispatches it to the matching handler.
+func lambdaHandler(proc *logprocessor.LogProcessor) func(context.Context, json.RawMessage) (any, error) {
+	return func(ctx context.Context, payload json.RawMessage) (any, error) {
+		var probe struct {
+			DetailType string `json:"detail-type"`
+			Records    []struct {
+				EventSource    string `json:"eventSource"`
+				SNSEventSource string `json:"EventSource"`
+			} `json:"Records"`
+		}
+		if err := json.Unmarshal(payload, &probe); err != nil {
+			return nil, err
+		}
+
+		var source string
+		if len(probe.Records) > 0 {
+			source = probe.Records[0].EventSource
+			if source == "" {
+				source = probe.Records[0].SNSEventSource
+			}
+		}
+
+		switch {
+		case source == "aws:sqs":
+			var event events.SQSEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, err
+			}
+			return proc.HandleSQSEvent(ctx, event)
+		case source == "aws:sns":
+			var event events.SNSEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, err
+			}
+			return nil, proc.HandleSNSEvent(ctx, event)
+		case probe.DetailType != "":
+			var event events.EventBridgeEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, err
+			}
+			return nil, proc.HandleEventBridgeEvent(ctx, event)
+		default:
+			var event events.S3Event
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, err
+			}
+			return nil, proc.HandleLambdaEvent(ctx, event)
+		}
+	}
+}